@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"tg-online-checker/internal/account"
+	"time"
+)
+
+const usage = `usage:
+  tg-online-checker                          запуск проверки
+  tg-online-checker accounts restore         список аккаунтов в карантине
+  tg-online-checker accounts restore ID...   вернуть аккаунты из карантина
+  tg-online-checker accounts restore --all   вернуть все аккаунты из карантина`
+
+// RunCommand выполняет служебную подкоманду вместо основной проверки.
+func RunCommand(cfg *Config, args []string) error {
+	if len(args) < 2 || args[0] != "accounts" {
+		return fmt.Errorf("unknown command\n%s", usage)
+	}
+
+	switch args[1] {
+	case "restore":
+		return restoreAccounts(cfg, args[2:])
+	default:
+		return fmt.Errorf("unknown accounts command %q\n%s", args[1], usage)
+	}
+}
+
+func restoreAccounts(cfg *Config, ids []string) error {
+	entries, err := account.Quarantined(cfg.Dir.Quarantine)
+	if err != nil {
+		return fmt.Errorf("cant read quarantine: %w", err)
+	}
+
+	if len(ids) == 0 {
+		fmt.Printf("В карантине: %d\n", len(entries))
+		for _, e := range entries {
+			fmt.Printf("  %s\t%s\t%s\n", e.ID, e.Reason, e.RetiredAt.Format(time.DateTime))
+		}
+		return nil
+	}
+
+	if len(ids) == 1 && ids[0] == "--all" {
+		ids = ids[:0]
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	var failed int
+	for _, id := range ids {
+		if err := account.Restore(cfg.Dir.Quarantine, cfg.Dir.Sessions, id); err != nil {
+			fmt.Printf("🚫 %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ %s restored\n", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to restore %d of %d accounts", failed, len(ids))
+	}
+	return nil
+}
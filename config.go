@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -13,13 +14,65 @@ type FileConfig struct {
 	Result string `env:"RESULT_FILE"`
 	Users  string `env:"USERS_FILE"`
 	Proxy  string `env:"PROXY_FILE"`
+	Audit  string `env:"AUDIT_FILE"`
 }
 type DirConfig struct {
-	Sessions string `env:"SESSIONS_DIR"`
+	Sessions   string `env:"SESSIONS_DIR"`
+	Quarantine string `env:"QUARANTINE_DIR" env-default:"quarantine"`
+}
+type ProxyConfig struct {
+	Mode             string        `env:"PROXY_MODE" env-default:"pool"`
+	RecheckInterval  time.Duration `env:"PROXY_RECHECK_INTERVAL" env-default:"5m"`
+	MaxFailures      int           `env:"PROXY_MAX_FAILURES" env-default:"3"`
+	SourceURL        string        `env:"PROXY_SOURCE_URL"`
+	SourceFormat     string        `env:"PROXY_SOURCE_FORMAT"`
+	RotateURL        string        `env:"PROXY_ROTATE_URL"`
+	RefreshInterval  time.Duration `env:"PROXY_REFRESH_INTERVAL" env-default:"10m"`
+	CheckTargets     []string      `env:"PROXY_CHECK_TARGETS" env-separator:","`
+	CheckConcurrency int           `env:"PROXY_CHECK_CONCURRENCY" env-default:"10"`
+	CheckTimeout     time.Duration `env:"PROXY_CHECK_TIMEOUT" env-default:"10s"`
+	CacheFile        string        `env:"PROXY_CACHE_FILE" env-default:"proxy-cache.json"`
+	CacheTTL         time.Duration `env:"PROXY_CACHE_TTL" env-default:"1h"`
+	EgressURL        string        `env:"PROXY_EGRESS_URL"`
+	GeoIPDB          string        `env:"PROXY_GEOIP_DB"`
+	GeoPolicy        string        `env:"PROXY_GEO_POLICY" env-default:"off"`
+}
+type ResultConfig struct {
+	Format            string        `env:"RESULT_FORMAT" env-default:"csv"`
+	Compression       string        `env:"RESULT_COMPRESSION"`
+	Filter            string        `env:"RESULT_FILTER"`
+	MaxErrors         int           `env:"RESULT_MAX_ERRORS"`
+	BufferSize        int           `env:"RESULT_BUFFER_SIZE" env-default:"1024"`
+	BufferPolicy      string        `env:"RESULT_BUFFER_POLICY" env-default:"block"`
+	SpillFile         string        `env:"RESULT_SPILL_FILE" env-default:"results.spill"`
+	CSVColumns        []string      `env:"RESULT_CSV_COLUMNS" env-separator:","`
+	CSVDelimiter      string        `env:"RESULT_CSV_DELIMITER" env-default:","`
+	CSVBOM            bool          `env:"RESULT_CSV_BOM"`
+	Append            bool          `env:"RESULT_APPEND"`
+	Dedupe            string        `env:"RESULT_DEDUPE"`
+	RotateRecords     int           `env:"RESULT_ROTATE_RECORDS"`
+	RotateSize        int64         `env:"RESULT_ROTATE_SIZE"`
+	RotateInterval    time.Duration `env:"RESULT_ROTATE_INTERVAL"`
+	RotateCompression string        `env:"RESULT_ROTATE_COMPRESSION"`
+	ParquetCodec      string        `env:"RESULT_PARQUET_CODEC" env-default:"snappy"`
+	ParquetRowGroup   int           `env:"RESULT_PARQUET_ROW_GROUP" env-default:"10000"`
+	Sinks             []string      `env:"RESULT_SINKS" env-separator:";"`
+	SinkTimeout       time.Duration `env:"RESULT_SINK_TIMEOUT" env-default:"10s"`
+	BatchSize         int           `env:"RESULT_BATCH_SIZE" env-default:"500"`
+	MaxRetries        int           `env:"RESULT_MAX_RETRIES" env-default:"3"`
+	PostgresDSN       string        `env:"RESULT_POSTGRES_DSN"`
+	UsersTable        string        `env:"RESULT_USERS_TABLE" env-default:"users"`
+	ObsTable          string        `env:"RESULT_OBSERVATIONS_TABLE" env-default:"observations"`
+	WebhookURL        string        `env:"RESULT_WEBHOOK_URL"`
+	Secret            string        `env:"RESULT_WEBHOOK_SECRET"`
+	SpoolDir          string        `env:"RESULT_WEBHOOK_SPOOL_DIR" env-default:"webhook-spool"`
+	Interval          time.Duration `env:"RESULT_WEBHOOK_INTERVAL" env-default:"5s"`
 }
 type Config struct {
 	Dir        DirConfig
 	File       FileConfig
+	Proxy      ProxyConfig
+	Result     ResultConfig
 	NumWorkers int `env:"NUM_WORKERS"`
 }
 
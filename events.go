@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"tg-online-checker/internal/account"
+)
+
+// logEvent пишет событие аккаунта в лог.
+func logEvent(e account.Event) {
+	if e.Reason != "" {
+		log.Printf("[events] [%s] %s: %s", e.AccountID, e.Type, e.Reason)
+		return
+	}
+	log.Printf("[events] [%s] %s", e.AccountID, e.Type)
+}
+
+// EventCounter считает события аккаунтов по типам.
+type EventCounter struct {
+	mu     sync.Mutex
+	counts map[account.EventType]int
+}
+
+func NewEventCounter() *EventCounter {
+	return &EventCounter{counts: map[account.EventType]int{}}
+}
+
+func (c *EventCounter) Handle(e account.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[e.Type]++
+}
+
+func (c *EventCounter) Print() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	types := make([]string, 0, len(c.counts))
+	for t := range c.counts {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+
+	fmt.Println("📈 События аккаунтов:")
+	for _, t := range types {
+		fmt.Printf("  %s: %d\n", t, c.counts[account.EventType(t)])
+	}
+}
+
+// AuditLog дописывает события аккаунтов в JSONL файл.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func NewAuditLog(filename string) (*AuditLog, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (a *AuditLog) Handle(e account.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.enc.Encode(e); err != nil {
+		log.Printf("[audit] cant write event: %v", err)
+	}
+}
+
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
@@ -1,6 +1,7 @@
 package account
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -23,15 +24,28 @@ type Account struct {
 	SessionPath string
 	StatePath   string
 	Proxy       *url.URL
-	Storage     *session.StorageMemory
-	Resolver    dcs.Resolver
-	IsBanned    bool
-	LastUsed    int64
-	lock        sync.Mutex
-	AppID       int
-	AppHash     string
-	FloodWait   int64
-	InUse       bool
+	BoundProxy  string
+	Transport   Transport
+	FixedProxy  *url.URL
+	Phone       string
+	Country     string
+	// DCAddr — адрес DC из сессии; прокси аккаунта должен его достигать.
+	DCAddr    string
+	Storage   *session.StorageMemory
+	Resolver  dcs.Resolver
+	IsBanned  bool
+	BanReason string
+	LastUsed  int64
+	lock      sync.Mutex
+	AppID     int
+	AppHash   string
+	FloodWait int64
+	InUse     bool
+	Retired   bool
+	events    *EventBus
+	// savedState — содержимое .state после последнего SaveState или
+	// LoadState; по нему отличаются собственные записи от правок оператора.
+	savedState []byte
 }
 
 type accountState struct {
@@ -39,8 +53,10 @@ type accountState struct {
 	AppID     int    `json:"app_id"`
 	AppHash   string `json:"app_hash"`
 	IsBanned  bool   `json:"is_banned"`
+	BanReason string `json:"ban_reason,omitempty"`
 	LastUsed  int64  `json:"last_used"`
 	FloodWait int64  `json:"flood_wait"`
+	Proxy     string `json:"proxy,omitempty"`
 }
 
 func getID(path string) string {
@@ -56,11 +72,16 @@ func NewAccount(path string, proxy *url.URL) *Account {
 	}
 
 	a.ID = getID(a.SessionPath)
-	a.StatePath = strings.TrimSuffix(a.SessionPath, filepath.Ext(a.SessionPath)) + ".state"
+	a.StatePath = sidecarPath(a.SessionPath, ".state")
 	a.LoadState()
 	if a.AppHash == "" || a.AppID == 0 {
 		a.TryLoadAppCredsFromJson()
 	}
+	a.loadTransport()
+	a.loadCountry()
+	if data, err := SqiteSession(a.SessionPath); err == nil {
+		a.DCAddr = data.Addr
+	}
 	return a
 
 }
@@ -75,29 +96,98 @@ func (a *Account) TryLoadAppCredsFromJson() {
 	}
 }
 
+// loadTransport читает из .json переопределение транспорта аккаунта:
+// "direct", "pool" или конкретный прокси.
+func (a *Account) loadTransport() {
+	value, err := getProxyOverride(a.SessionPath)
+	if err == nil {
+		var transport Transport
+		var fixed *url.URL
+		transport, fixed, err = parseTransport(value)
+		if err == nil {
+			a.lock.Lock()
+			a.Transport = transport
+			a.FixedProxy = fixed
+			a.lock.Unlock()
+			return
+		}
+	}
+	log.Printf("⚠️ Cant read transport for [%s], using default: %v", a.ID, err)
+}
+
+// loadCountry определяет страну аккаунта по номеру телефона из .json,
+// а если его нет — по имени сессии, когда оно похоже на номер.
+func (a *Account) loadCountry() {
+	phone, err := getPhone(a.SessionPath)
+	if err != nil {
+		log.Printf("⚠️ Cant read phone for [%s]: %v", a.ID, err)
+	}
+	if phone == "" && isPhone(a.ID) {
+		phone = a.ID
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.Phone = phone
+	a.Country = countryByPhone(phone)
+}
+
 func (a *Account) IsValid() bool {
 
-	return !a.IsBanned && a.FloodWait == 0
+	return !a.IsBanned && !a.Retired && a.FloodWait == 0
 }
 
 func (a *Account) SetFloodWait(seconds int) {
 	a.lock.Lock()
-	defer a.lock.Unlock()
 	now := time.Now().Unix()
 	fmt.Printf("ПОСТАВИЛИ FLOOD WAIT [%s]: %d\n", a.ID, now+int64(seconds))
 	a.FloodWait = now + int64(seconds)
+	a.lock.Unlock()
+	a.publish(EventFloodWait, fmt.Sprintf("FLOOD_WAIT %ds", seconds))
 }
 
-func (a *Account) MarkBanned() {
+// MarkBanned помечает аккаунт нерабочим (бан, деактивация, отозванная
+// авторизация) с указанием причины.
+func (a *Account) MarkBanned(reason string) {
 	a.lock.Lock()
-	defer a.lock.Unlock()
 	a.IsBanned = true
+	a.BanReason = reason
+	a.lock.Unlock()
+	a.publish(EventBanned, reason)
 }
 
 func (a *Account) Release() {
 	a.lock.Lock()
-	defer a.lock.Unlock()
 	a.InUse = false
+	a.lock.Unlock()
+	a.publish(EventReleased, "")
+}
+
+// GetResolver возвращает текущий резолвер аккаунта: он может смениться,
+// если прокси аккаунта упал.
+func (a *Account) GetResolver() dcs.Resolver {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.Resolver
+}
+
+// ReportError сообщает подписчикам об ошибке, полученной на аккаунте.
+func (a *Account) ReportError(err error) {
+	a.publish(EventError, err.Error())
+}
+
+// clearExpiredFloodWait снимает истёкший flood wait. Вызывается под a.lock.
+func (a *Account) clearExpiredFloodWait(now int64) bool {
+	if a.FloodWait > 0 && now >= a.FloodWait {
+		a.FloodWait = 0
+		return true
+	}
+	return false
+}
+
+// publish отправляет событие аккаунта. Нельзя вызывать под a.lock.
+func (a *Account) publish(t EventType, reason string) {
+	a.events.Publish(Event{Type: t, AccountID: a.ID, Reason: reason})
 }
 
 func (a *Account) LoadState() error {
@@ -117,9 +207,12 @@ func (a *Account) LoadState() error {
 	}
 
 	a.lock.Lock()
+	a.savedState = data
 	a.AppHash = state.AppHash
 	a.AppID = state.AppID
 	a.IsBanned = state.IsBanned
+	a.BanReason = state.BanReason
+	a.BoundProxy = state.Proxy
 
 	a.LastUsed = state.LastUsed
 
@@ -140,8 +233,10 @@ func (a *Account) SaveState() error {
 		AppID:     a.AppID,
 		AppHash:   a.AppHash,
 		IsBanned:  a.IsBanned,
+		BanReason: a.BanReason,
 		LastUsed:  a.LastUsed,
 		FloodWait: a.FloodWait,
+		Proxy:     a.BoundProxy,
 	}
 	a.lock.Unlock()
 
@@ -155,5 +250,20 @@ func (a *Account) SaveState() error {
 		log.Printf("failed to write state for %s: %v", a.ID, err)
 		return err
 	}
+	a.lock.Lock()
+	a.savedState = data
+	a.lock.Unlock()
 	return nil
 }
+
+// stateEdited сообщает, что .state на диске отличается от того, что
+// аккаунт сохранил или прочитал последним, то есть файл изменил не он.
+func (a *Account) stateEdited() bool {
+	data, err := os.ReadFile(a.StatePath)
+	if err != nil {
+		return false
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return !bytes.Equal(data, a.savedState)
+}
@@ -0,0 +1,353 @@
+package account
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"tg-online-checker/internal/proxy"
+
+	"github.com/gotd/td/telegram/dcs"
+)
+
+// bindProxy назначает аккаунту транспорт. Для аккаунтов из пула
+// сохранённая в state привязка к прокси сохраняется между запусками; новый
+// прокси назначается только если привязки нет или закреплённый прокси не
+// прошёл проверку. Вызывается под am.mu.
+func (am *AccountManager) bindProxy(acc *Account) error {
+	acc.lock.Lock()
+	bound := acc.BoundProxy
+	transport := acc.Transport
+	fixed := acc.FixedProxy
+	country := acc.Country
+	dc := acc.DCAddr
+	acc.lock.Unlock()
+
+	if transport == TransportDefault {
+		transport = TransportPool
+		if am.pool == nil {
+			transport = TransportDirect
+		}
+	}
+
+	switch transport {
+	case TransportDirect:
+		am.setTransport(acc, TransportDirect, nil)
+		return nil
+	case TransportFixed:
+		am.setTransport(acc, TransportFixed, fixed)
+		return nil
+	}
+
+	if am.pool == nil {
+		return fmt.Errorf("account [%s] requires proxy pool, but proxies are disabled", acc.ID)
+	}
+	reason := "no proxy bound"
+	if bound != "" {
+		reason = "bound proxy " + bound + " is dead"
+		if p := am.findProxy(bound); p != nil {
+			switch {
+			case !am.pool.Reaches(p, dc):
+				reason = "bound proxy " + bound + " cant reach DC " + dc
+			case !am.geoMatches(country, p):
+				reason = "bound proxy " + bound + " is not in " + country
+			default:
+				am.setTransport(acc, TransportPool, p)
+				return nil
+			}
+		}
+	}
+
+	p := am.leastBoundProxy(country, dc, "")
+	if p == nil {
+		if am.geo == GeoStrict && country != "" {
+			return fmt.Errorf("no working proxy in %s for [%s]", country, acc.ID)
+		}
+		return fmt.Errorf("no working proxy for [%s]", acc.ID)
+	}
+	am.setTransport(acc, TransportPool, p)
+	log.Printf("🔗 Account [%s] bound to proxy %s: %s", acc.ID, proxy.Key(p), reason)
+
+	if err := acc.SaveState(); err != nil {
+		log.Printf("⚠️ Cant persist proxy binding for [%s]: %v", acc.ID, err)
+	}
+	return nil
+}
+
+// reloadTransport перечитывает транспорт из .json аккаунта, заново
+// назначает его и пересоздаёт резолвер, через который подключается воркер.
+// Занятый аккаунт подключится через новый транспорт при следующем запуске.
+// Если новый транспорт назначить не удалось, аккаунт остаётся на прежнем.
+// Вызывается под am.mu.
+func (am *AccountManager) reloadTransport(acc *Account) {
+	acc.lock.Lock()
+	oldTransport, oldProxy, oldFixed := acc.Transport, acc.Proxy, acc.FixedProxy
+	acc.lock.Unlock()
+
+	am.unbindProxy(acc)
+	acc.loadTransport()
+	err := am.bindProxy(acc)
+	var resolver dcs.Resolver
+	if err == nil {
+		if resolver, err = getResolver(acc); err != nil {
+			am.unbindProxy(acc)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Cant apply new transport for [%s], keeping %s: %v", acc.ID, transportString(oldTransport, oldProxy), err)
+		am.setTransport(acc, oldTransport, oldProxy)
+		acc.lock.Lock()
+		acc.FixedProxy = oldFixed
+		acc.lock.Unlock()
+		return
+	}
+
+	acc.lock.Lock()
+	acc.Resolver = resolver
+	transport, p := acc.Transport, acc.Proxy
+	acc.lock.Unlock()
+	if err := acc.SaveState(); err != nil {
+		log.Printf("⚠️ Cant persist proxy binding for [%s]: %v", acc.ID, err)
+	}
+	if transport != oldTransport || proxy.Key(p) != proxy.Key(oldProxy) {
+		log.Printf("🔀 Account [%s] transport changed: %s -> %s", acc.ID, transportString(oldTransport, oldProxy), transportString(transport, p))
+	}
+}
+
+// applyBoundProxy переносит аккаунт на прокси из BoundProxy, если оператор
+// поменял его в .state. Если такого прокси нет в пуле или он не достаёт до
+// DC аккаунта, привязка остаётся прежней и записывается обратно в .state.
+// Вызывается под am.mu.
+func (am *AccountManager) applyBoundProxy(acc *Account) {
+	acc.lock.Lock()
+	pooled := acc.Transport == TransportPool
+	bound, current := acc.BoundProxy, proxy.Key(acc.Proxy)
+	dc := acc.DCAddr
+	acc.lock.Unlock()
+	if !pooled || bound == current {
+		return
+	}
+
+	p := am.findProxy(bound)
+	switch {
+	case p == nil || !am.pool.Reaches(p, dc):
+		log.Printf("⚠️ Proxy %s from state of [%s] is not available, keeping %s", bound, acc.ID, current)
+	default:
+		err := am.rebind(acc, p)
+		if err == nil {
+			log.Printf("🔀 Account [%s] moved from %s to %s: state edited", acc.ID, current, bound)
+			am.emit(acc, EventProxyChanged, current+" -> "+bound)
+			return
+		}
+		log.Printf("⚠️ Cant move [%s] to proxy %s: %v", acc.ID, bound, err)
+	}
+
+	acc.lock.Lock()
+	acc.BoundProxy = current
+	acc.lock.Unlock()
+	if err := acc.SaveState(); err != nil {
+		log.Printf("⚠️ Cant persist proxy binding for [%s]: %v", acc.ID, err)
+	}
+}
+
+// transportString описывает транспорт для логов.
+func transportString(t Transport, p *url.URL) string {
+	if p == nil {
+		return string(t)
+	}
+	return string(t) + " " + proxy.ProxyString(p)
+}
+
+// unbindProxy снимает учёт привязки аккаунта к прокси пула, не трогая state.
+// Вызывается под am.mu.
+func (am *AccountManager) unbindProxy(acc *Account) {
+	acc.lock.Lock()
+	key := proxy.Key(acc.Proxy)
+	pooled := acc.Transport == TransportPool
+	acc.lock.Unlock()
+	if pooled && am.bindings[key] > 0 {
+		am.bindings[key]--
+	}
+}
+
+// setTransport назначает аккаунту транспорт и прокси. В привязках и
+// state учитываются только прокси из пула. Вызывается под am.mu.
+func (am *AccountManager) setTransport(acc *Account, transport Transport, p *url.URL) {
+	key := proxy.Key(p)
+	if transport == TransportPool {
+		am.bindings[key]++
+	}
+
+	acc.lock.Lock()
+	defer acc.lock.Unlock()
+	acc.Transport = transport
+	acc.Proxy = p
+	if transport == TransportPool {
+		acc.BoundProxy = key
+	}
+}
+
+// findProxy ищет живой прокси по ключу привязки.
+func (am *AccountManager) findProxy(key string) *url.URL {
+	if am.pool == nil {
+		return nil
+	}
+	for _, p := range am.pool.URLs() {
+		if proxy.Key(p) == key {
+			return p
+		}
+	}
+	return nil
+}
+
+// leastBoundProxy возвращает рабочий прокси с наименьшим числом привязанных
+// аккаунтов; при равенстве — лучший по оценке. exclude — ключ прокси,
+// который выбирать нельзя. country — страна аккаунта: с политикой GeoPrefer
+// прокси из неё выбираются в первую очередь, с GeoStrict — только они.
+// dc — адрес DC аккаунта: прокси, которые до него не достают, пропускаются.
+func (am *AccountManager) leastBoundProxy(country, dc, exclude string) *url.URL {
+	if am.pool == nil {
+		return nil
+	}
+	var best, bestLocal *url.URL
+	for _, p := range am.pool.URLs() {
+		if proxy.Key(p) == exclude || !am.pool.Reaches(p, dc) {
+			continue
+		}
+		if best == nil || am.bindings[proxy.Key(p)] < am.bindings[proxy.Key(best)] {
+			best = p
+		}
+		if am.geo != GeoOff && country != "" && am.pool.Country(p) == country {
+			if bestLocal == nil || am.bindings[proxy.Key(p)] < am.bindings[proxy.Key(bestLocal)] {
+				bestLocal = p
+			}
+		}
+	}
+	if bestLocal != nil || (am.geo == GeoStrict && country != "") {
+		return bestLocal
+	}
+	return best
+}
+
+// geoMatches сообщает, подходит ли прокси аккаунту из country по политике.
+// Несовпадение страны запрещает прокси только с GeoStrict.
+func (am *AccountManager) geoMatches(country string, p *url.URL) bool {
+	if am.geo != GeoStrict || country == "" {
+		return true
+	}
+	return am.pool.Country(p) == country
+}
+
+// failover переводит аккаунты с упавшего прокси на запасной рабочий прокси.
+func (am *AccountManager) failover(dead *url.URL, reason string) {
+	am.mu.Lock()
+	defer am.unlock()
+
+	deadKey := proxy.Key(dead)
+	for _, acc := range am.accounts {
+		acc.lock.Lock()
+		bound := acc.Transport == TransportPool && proxy.Key(acc.Proxy) == deadKey
+		country := acc.Country
+		dc := acc.DCAddr
+		acc.lock.Unlock()
+		if !bound {
+			continue
+		}
+
+		spare := am.leastBoundProxy(country, dc, deadKey)
+		if spare == nil {
+			log.Printf("⚠️ No spare proxy for [%s], staying on %s", acc.ID, deadKey)
+			continue
+		}
+		if err := am.rebind(acc, spare); err != nil {
+			log.Printf("⚠️ Cant move [%s] to proxy %s: %v", acc.ID, proxy.Key(spare), err)
+			continue
+		}
+		log.Printf("🔀 Account [%s] moved from %s to %s: %s", acc.ID, deadKey, proxy.Key(spare), reason)
+		am.emit(acc, EventProxyChanged, deadKey+" -> "+proxy.Key(spare))
+	}
+}
+
+// rebind переносит аккаунт на другой прокси и пересоздаёт резолвер.
+// Занятый аккаунт подключится через новый прокси при следующем запуске.
+// Вызывается под am.mu.
+func (am *AccountManager) rebind(acc *Account, p *url.URL) error {
+	acc.lock.Lock()
+	old := acc.Proxy
+	acc.lock.Unlock()
+
+	am.unbindProxy(acc)
+	am.setTransport(acc, TransportPool, p)
+	resolver, err := getResolver(acc)
+	if err != nil {
+		am.unbindProxy(acc)
+		am.setTransport(acc, TransportPool, old)
+		return err
+	}
+
+	acc.lock.Lock()
+	acc.Resolver = resolver
+	acc.lock.Unlock()
+	if err := acc.SaveState(); err != nil {
+		log.Printf("⚠️ Cant persist proxy binding for [%s]: %v", acc.ID, err)
+	}
+	return nil
+}
+
+// ReportProxySuccess сообщает пулу, что аккаунт успешно подключился через свой прокси.
+func (am *AccountManager) ReportProxySuccess(acc *Account) {
+	if am.pool == nil {
+		return
+	}
+	acc.lock.Lock()
+	p := acc.Proxy
+	acc.lock.Unlock()
+	am.pool.ReportSuccess(p)
+}
+
+// ReportProxyFailure сообщает пулу об ошибке подключения аккаунта через
+// прокси. Учитываются только ошибки подключения, рукопожатия и транспорта
+// (см. proxy.IsTransportError): RPC ошибки и ошибки приложения прокси не
+// касаются.
+func (am *AccountManager) ReportProxyFailure(acc *Account, err error) {
+	if am.pool == nil || !proxy.IsTransportError(err) {
+		return
+	}
+	acc.lock.Lock()
+	p := acc.Proxy
+	acc.lock.Unlock()
+	am.pool.ReportFailure(p, err)
+}
+
+// Transport — способ подключения аккаунта к Telegram.
+type Transport string
+
+const (
+	// TransportDefault — режим не задан в .json: используется пул прокси,
+	// а если прокси отключены — прямое подключение.
+	TransportDefault Transport = ""
+	// TransportPool — прокси из общего пула с закреплением и failover.
+	TransportPool Transport = "pool"
+	// TransportDirect — подключение без прокси.
+	TransportDirect Transport = "direct"
+	// TransportFixed — конкретный прокси из .json аккаунта, вне пула.
+	TransportFixed Transport = "fixed"
+)
+
+// parseTransport разбирает значение "proxy" из .json аккаунта:
+// "direct", "pool" или строку прокси в любом поддерживаемом формате.
+func parseTransport(value string) (Transport, *url.URL, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return TransportDefault, nil, nil
+	case "direct", "none":
+		return TransportDirect, nil, nil
+	case "pool":
+		return TransportPool, nil, nil
+	}
+	u, err := proxy.Parse(value)
+	if err != nil {
+		return TransportDefault, nil, fmt.Errorf("invalid proxy override: %w", err)
+	}
+	return TransportFixed, u, nil
+}
@@ -0,0 +1,126 @@
+package account
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/telegram/dcs"
+	"github.com/gotd/td/tg"
+)
+
+// testAccount создаёт аккаунт без файла сессии: транспорт и state
+// читаются из временной директории.
+func testAccount(t *testing.T, override string) *Account {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.session")
+	acc := &Account{ID: "test", SessionPath: path, StatePath: sidecarPath(path, ".state")}
+	writeOverride(t, acc, override)
+	return acc
+}
+
+func writeOverride(t *testing.T, acc *Account, override string) {
+	t.Helper()
+	data := `{"proxy": "` + override + `"}`
+	if err := os.WriteFile(sidecarPath(acc.SessionPath, ".json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testManager() *AccountManager {
+	return &AccountManager{bindings: map[string]int{}, events: NewEventBus()}
+}
+
+// dialsThrough сообщает, идёт ли подключение резолвера к DC через прокси,
+// слушающий на ln.
+func dialsThrough(t *testing.T, r dcs.Resolver, ln net.Listener) bool {
+	t.Helper()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		accepted <- struct{}{}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	list := dcs.List{Options: []tg.DCOption{{ID: 2, IPAddress: "192.0.2.1", Port: 443}}}
+	if conn, err := r.Primary(ctx, 2, list); err == nil {
+		conn.Close()
+	}
+
+	select {
+	case <-accepted:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func TestReloadTransportRebuildsResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	am := testManager()
+	acc := testAccount(t, "direct")
+	acc.loadTransport()
+	if err := am.bindProxy(acc); err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := getResolver(acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.Resolver = resolver
+
+	writeOverride(t, acc, "http://"+ln.Addr().String())
+	am.reloadTransport(acc)
+
+	if acc.Transport != TransportFixed || acc.Proxy == nil || acc.Proxy.Host != ln.Addr().String() {
+		t.Fatalf("transport = %s %v, want fixed %s", acc.Transport, acc.Proxy, ln.Addr())
+	}
+	if !dialsThrough(t, acc.GetResolver(), ln) {
+		t.Error("worker resolver does not dial through the new proxy")
+	}
+}
+
+func TestReloadTransportKeepsOldOnFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	am := testManager()
+	acc := testAccount(t, "http://"+ln.Addr().String())
+	acc.loadTransport()
+	if err := am.bindProxy(acc); err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := getResolver(acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.Resolver = resolver
+	fixed := acc.FixedProxy
+
+	// без пула прокси транспорт "pool" назначить нельзя
+	writeOverride(t, acc, "pool")
+	am.reloadTransport(acc)
+
+	if acc.Transport != TransportFixed || acc.FixedProxy != fixed || acc.Proxy != fixed {
+		t.Errorf("transport = %s %v (fixed %v), want the previous fixed proxy %v", acc.Transport, acc.Proxy, acc.FixedProxy, fixed)
+	}
+	if !dialsThrough(t, acc.GetResolver(), ln) {
+		t.Error("worker resolver no longer dials through the previous proxy")
+	}
+}
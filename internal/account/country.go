@@ -0,0 +1,99 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+)
+
+// callingCodes сопоставляет телефонный код страны с ISO кодом страны.
+// Для кодов, общих для нескольких стран (+1, +7), выбрана основная страна.
+var callingCodes = map[string]string{
+	"1": "US", "1204": "CA", "1226": "CA", "1236": "CA", "1249": "CA", "1250": "CA",
+	"1289": "CA", "1306": "CA", "1343": "CA", "1365": "CA", "1403": "CA", "1416": "CA",
+	"1418": "CA", "1431": "CA", "1437": "CA", "1438": "CA", "1450": "CA", "1506": "CA",
+	"1514": "CA", "1519": "CA", "1548": "CA", "1579": "CA", "1581": "CA", "1587": "CA",
+	"1604": "CA", "1613": "CA", "1639": "CA", "1647": "CA", "1672": "CA", "1705": "CA",
+	"1709": "CA", "1778": "CA", "1780": "CA", "1782": "CA", "1807": "CA", "1819": "CA",
+	"1825": "CA", "1867": "CA", "1873": "CA", "1902": "CA", "1905": "CA",
+	"7": "RU", "76": "KZ", "77": "KZ",
+	"20": "EG", "27": "ZA", "30": "GR", "31": "NL", "32": "BE", "33": "FR", "34": "ES",
+	"36": "HU", "39": "IT", "40": "RO", "41": "CH", "43": "AT", "44": "GB", "45": "DK",
+	"46": "SE", "47": "NO", "48": "PL", "49": "DE", "51": "PE", "52": "MX", "53": "CU",
+	"54": "AR", "55": "BR", "56": "CL", "57": "CO", "58": "VE", "60": "MY", "61": "AU",
+	"62": "ID", "63": "PH", "64": "NZ", "65": "SG", "66": "TH", "81": "JP", "82": "KR",
+	"84": "VN", "86": "CN", "90": "TR", "91": "IN", "92": "PK", "93": "AF", "94": "LK",
+	"95": "MM", "98": "IR",
+	"211": "SS", "212": "MA", "213": "DZ", "216": "TN", "218": "LY", "220": "GM",
+	"221": "SN", "233": "GH", "234": "NG", "237": "CM", "244": "AO", "249": "SD",
+	"251": "ET", "254": "KE", "255": "TZ", "256": "UG", "260": "ZM", "263": "ZW",
+	"351": "PT", "352": "LU", "353": "IE", "354": "IS", "355": "AL", "356": "MT",
+	"357": "CY", "358": "FI", "359": "BG", "370": "LT", "371": "LV", "372": "EE",
+	"373": "MD", "374": "AM", "375": "BY", "376": "AD", "377": "MC", "380": "UA",
+	"381": "RS", "382": "ME", "385": "HR", "386": "SI", "387": "BA", "389": "MK",
+	"420": "CZ", "421": "SK", "423": "LI",
+	"852": "HK", "853": "MO", "855": "KH", "856": "LA", "880": "BD", "886": "TW",
+	"960": "MV", "961": "LB", "962": "JO", "963": "SY", "964": "IQ", "965": "KW",
+	"966": "SA", "967": "YE", "968": "OM", "970": "PS", "971": "AE", "972": "IL",
+	"973": "BH", "974": "QA", "975": "BT", "976": "MN", "977": "NP",
+	"992": "TJ", "993": "TM", "994": "AZ", "995": "GE", "996": "KG", "998": "UZ",
+}
+
+// maxCallingCodeLen — длина самого длинного префикса в callingCodes.
+const maxCallingCodeLen = 4
+
+// countryByPhone возвращает ISO код страны по номеру телефона в
+// международном формате. Выбирается самый длинный подходящий префикс.
+func countryByPhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+
+	for n := min(maxCallingCodeLen, len(digits)); n > 0; n-- {
+		if country, ok := callingCodes[digits[:n]]; ok {
+			return country
+		}
+	}
+	return ""
+}
+
+// isPhone сообщает, похожа ли строка на номер телефона (например, имя
+// файла сессии "79991234567").
+func isPhone(s string) bool {
+	s = strings.TrimPrefix(s, "+")
+	if len(s) < 7 || len(s) > 15 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GeoPolicy определяет, как учитывается страна прокси при привязке к аккаунту.
+type GeoPolicy string
+
+const (
+	// GeoOff — страна прокси не учитывается.
+	GeoOff GeoPolicy = "off"
+	// GeoPrefer — прокси из страны номера выбираются в первую очередь,
+	// при их отсутствии используется любой рабочий прокси.
+	GeoPrefer GeoPolicy = "prefer"
+	// GeoStrict — аккаунт привязывается только к прокси из страны номера.
+	GeoStrict GeoPolicy = "strict"
+)
+
+// ParseGeoPolicy разбирает значение политики из конфига.
+func ParseGeoPolicy(value string) (GeoPolicy, error) {
+	switch policy := GeoPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return GeoOff, nil
+	case GeoOff, GeoPrefer, GeoStrict:
+		return policy, nil
+	}
+	return GeoOff, fmt.Errorf("unknown geo policy %q", value)
+}
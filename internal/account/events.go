@@ -0,0 +1,67 @@
+package account
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType — тип события жизненного цикла аккаунта.
+type EventType string
+
+const (
+	EventAcquired     EventType = "acquired"
+	EventReleased     EventType = "released"
+	EventFloodWait    EventType = "flood_wait"
+	EventBanned       EventType = "banned"
+	EventRecovered    EventType = "recovered"
+	EventError        EventType = "error"
+	EventAdded        EventType = "added"
+	EventRetired      EventType = "retired"
+	EventQuarantined  EventType = "quarantined"
+	EventProxyChanged EventType = "proxy_changed"
+)
+
+// Event — изменение состояния аккаунта.
+type Event struct {
+	Type      EventType `json:"type"`
+	AccountID string    `json:"account_id"`
+	Time      time.Time `json:"time"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// EventBus рассылает события аккаунтов подписчикам. Подписчики вызываются
+// синхронно в горутине, где произошло событие, поэтому не должны блокироваться.
+// Блокировки менеджера и аккаунта к этому моменту сняты, так что подписчик
+// может обращаться к AccountManager.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers []func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe добавляет обработчик событий.
+func (b *EventBus) Subscribe(handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish отправляет событие всем подписчикам. Безопасен для nil.
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(e)
+	}
+}
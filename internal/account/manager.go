@@ -3,8 +3,9 @@ package account
 import (
 	"fmt"
 	"log"
-	"net/url"
+	"path/filepath"
 	"sync"
+	"tg-online-checker/internal/proxy"
 	"time"
 
 	"github.com/pkg/errors"
@@ -30,6 +31,25 @@ func (s *managerTotals) update(acc *Account) {
 	}
 }
 
+// Totals — счётчики аккаунтов менеджера.
+type Totals struct {
+	Total  int
+	Valid  int
+	Banned int
+	Flood  int
+}
+
+func (s *managerTotals) snapshot() Totals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Totals{
+		Total:  s.total,
+		Valid:  s.validCount,
+		Banned: s.bannedCount,
+		Flood:  s.floodCount,
+	}
+}
+
 func (s *managerTotals) print() {
 	fmt.Printf("📊 Аккаунтов: %d\n", s.total)
 	fmt.Printf("✅ Рабочих: %d\n", s.validCount)
@@ -40,59 +60,132 @@ func (s *managerTotals) print() {
 
 // AccountManager управляет пулом аккаунтов.
 type AccountManager struct {
-	accounts []*Account
-	mu       sync.Mutex
-	totals   *managerTotals
+	accounts   []*Account
+	mu         sync.Mutex
+	totals     *managerTotals
+	sessionDir string
+	// quarantineDir — куда переносятся забаненные и деактивированные сессии.
+	// Пустая строка отключает карантин.
+	quarantineDir string
+	pool          *proxy.Pool
+	// geo — как учитывать страну прокси при привязке аккаунтов.
+	geo      GeoPolicy
+	bindings map[string]int
+	events   *EventBus
+	// pending — события, произошедшие под am.mu; отправляются в unlock.
+	pending []Event
+	// rejected — сессии, которые уже учтены в totals, но не попали в пул.
+	// Повторные события по ним не должны считать аккаунт ещё раз.
+	rejected map[string]bool
 }
 
-// NewManager создаёт менеджер аккаунтов на основе сессий и списка прокси.
-func NewManager(sessionDir string, proxies []*url.URL) (*AccountManager, error) {
-	sessionPaths, err := getSessionFiles(sessionDir)
+// NewManager создаёт менеджер аккаунтов на основе сессий и пула прокси.
+// Аккаунты с упавших прокси автоматически переводятся на запасные.
+// Если pool равен nil, аккаунты по умолчанию подключаются напрямую;
+// переопределить транспорт можно ключом "proxy" в .json аккаунта.
+// geo задаёт, учитывается ли при привязке страна прокси и номера.
+func NewManager(sessionDir, quarantineDir string, pool *proxy.Pool, geo GeoPolicy) (*AccountManager, error) {
+	sessionPaths, err := getSessionFiles(sessionDir, quarantineDir)
 	if err != nil {
 		return nil, err
 	}
-	if len(sessionPaths) == 0 || len(proxies) == 0 {
-		return nil, errors.New("empty session list or proxy list")
+	if len(sessionPaths) == 0 {
+		return nil, errors.New("empty session list")
 	}
 
-	totals := &managerTotals{sessionDir: sessionDir, total: len(sessionPaths)}
-
-	accs := make([]*Account, 0, len(sessionPaths))
-	for i, path := range sessionPaths {
+	am := &AccountManager{
+		sessionDir:    sessionDir,
+		quarantineDir: quarantineDir,
+		pool:          pool,
+		geo:           geo,
+		bindings:      map[string]int{},
+		rejected:      map[string]bool{},
+		events:        NewEventBus(),
+		totals:        &managerTotals{sessionDir: sessionDir, total: len(sessionPaths)},
+	}
 
-		acc := NewAccount(path, proxies[i%len(proxies)])
-		totals.update(acc)
+	for _, path := range sessionPaths {
+		acc := am.newAccount(path)
+		am.totals.update(acc)
 
-		if !acc.IsValid() {
+		if acc.IsBanned {
+			// в totals сессия уже учтена, даже если карантин выключен
+			am.rejected[path] = true
+			am.quarantine(acc)
 			continue
 		}
-
-		storage, err := getStorage(acc)
-		if err != nil {
-			log.Printf("⚠️ Cant create storage for [%s]: %v", acc.ID, err)
+		if !acc.IsValid() {
+			am.rejected[path] = true
 			continue
 		}
-		acc.Storage = storage
+		if err := am.admit(acc); err != nil {
+			am.rejected[path] = true
+			log.Printf("⚠️ %v", err)
+		}
+	}
 
-		resolver, err := getResolver(acc)
-		if err != nil {
-			log.Printf("⚠️ Cant create resolver for [%s]: %v", acc.ID, err)
-			continue
+	// подписчиков у новой шины ещё нет
+	am.pending = nil
+
+	if pool != nil {
+		if len(am.accounts) == 0 && len(pool.URLs()) == 0 {
+			return nil, errors.New("empty proxy list")
 		}
-		acc.Resolver = resolver
+		pool.OnDown(am.failover)
+	}
+	return am, nil
+}
+
+func (am *AccountManager) newAccount(path string) *Account {
+	acc := NewAccount(path, nil)
+	acc.events = am.events
+	return acc
+}
+
+// admit закрепляет за аккаунтом прокси, готовит сессию и добавляет
+// аккаунт в пул. Вызывается под am.mu.
+func (am *AccountManager) admit(acc *Account) error {
+	if err := am.bindProxy(acc); err != nil {
+		return err
+	}
+	if err := prepareAccount(acc); err != nil {
+		am.unbindProxy(acc)
+		return err
+	}
 
-		totals.validCount++
+	am.totals.validCount++
+	am.accounts = append(am.accounts, acc)
+	return nil
+}
 
-		accs = append(accs, acc)
+// prepareAccount готовит хранилище сессии и резолвер для аккаунта.
+func prepareAccount(acc *Account) error {
+	storage, err := getStorage(acc)
+	if err != nil {
+		return fmt.Errorf("cant create storage for [%s]: %w", acc.ID, err)
+	}
+	resolver, err := getResolver(acc)
+	if err != nil {
+		return fmt.Errorf("cant create resolver for [%s]: %w", acc.ID, err)
 	}
 
-	return &AccountManager{accounts: accs, totals: totals}, nil
+	acc.lock.Lock()
+	defer acc.lock.Unlock()
+	acc.Storage = storage
+	acc.Resolver = resolver
+	return nil
 }
 
 func (am *AccountManager) PrintTotals() {
 	am.totals.print()
 }
 
+// Totals возвращает текущие счётчики аккаунтов, например для итогов
+// в файле результатов.
+func (am *AccountManager) Totals() Totals {
+	return am.totals.snapshot()
+}
+
 func (am *AccountManager) RefreshTotals() {
 	am.mu.Lock()
 	defer am.mu.Unlock()
@@ -119,22 +212,184 @@ func (am *AccountManager) Shutdown() error {
 }
 
 func (am *AccountManager) GetAccounts() []*Account {
-	return am.accounts
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return append([]*Account{}, am.accounts...)
 }
 
 func (am *AccountManager) GetAvailable() *Account {
 	am.mu.Lock()
-	defer am.mu.Unlock()
+	defer am.unlock()
+	am.prune()
+	now := time.Now().Unix()
 	for _, acc := range am.accounts {
 		acc.lock.Lock()
+		recovered := acc.clearExpiredFloodWait(now)
 		if acc.IsValid() && !acc.InUse {
 
-			acc.LastUsed = time.Now().Unix()
+			acc.LastUsed = now
 			acc.InUse = true // 👈 помечаем как занятый
 			acc.lock.Unlock()
+			if recovered {
+				am.emit(acc, EventRecovered, "flood wait expired")
+			}
+			am.emit(acc, EventAcquired, "")
 			return acc
 		}
 		acc.lock.Unlock()
+		if recovered {
+			am.emit(acc, EventRecovered, "flood wait expired")
+		}
+	}
+	return nil
+}
+
+// Events возвращает шину событий жизненного цикла аккаунтов.
+func (am *AccountManager) Events() *EventBus {
+	return am.events
+}
+
+// AddSession добавляет в пул аккаунт из нового файла сессии.
+func (am *AccountManager) AddSession(path string) error {
+	am.mu.Lock()
+	defer am.unlock()
+	if acc := am.find(path); acc != nil {
+		acc.lock.Lock()
+		acc.Retired = false
+		acc.lock.Unlock()
+		return nil
+	}
+
+	// отклонённую ранее сессию пробуем принять снова, но в totals она уже есть
+	acc := am.newAccount(path)
+	if !am.rejected[path] {
+		am.rejected[path] = true
+		am.totals.total++
+		am.totals.update(acc)
+	}
+	if !acc.IsValid() {
+		return fmt.Errorf("account [%s] is not valid", acc.ID)
+	}
+	if err := am.admit(acc); err != nil {
+		return err
+	}
+	delete(am.rejected, path)
+	log.Printf("➕ Account [%s] added", acc.ID)
+	am.emit(acc, EventAdded, "")
+	return nil
+}
+
+// RetireSession выводит аккаунт из пула. Если аккаунт сейчас занят,
+// он будет удалён после того, как воркер его освободит.
+func (am *AccountManager) RetireSession(path string) {
+	am.mu.Lock()
+	defer am.unlock()
+	acc := am.find(path)
+	if acc == nil {
+		return
+	}
+	acc.lock.Lock()
+	acc.Retired = true
+	acc.lock.Unlock()
+	am.prune()
+	log.Printf("➖ Account [%s] retired", acc.ID)
+	am.emit(acc, EventRetired, "session file removed")
+}
+
+// ReloadSidecar перечитывает .json или .state файл аккаунта.
+func (am *AccountManager) ReloadSidecar(path string) {
+	am.mu.Lock()
+	acc := am.find(sessionPathFor(path))
+	am.mu.Unlock()
+	if acc == nil {
+		return
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		acc.TryLoadAppCredsFromJson()
+		acc.loadCountry()
+		am.mu.Lock()
+		am.reloadTransport(acc)
+		am.mu.Unlock()
+	case ".state":
+		// менеджер сам пишет .state при привязке и карантине — такие
+		// события не должны затирать то, что изменилось в памяти после записи
+		if !acc.stateEdited() {
+			return
+		}
+		acc.LoadState()
+		am.mu.Lock()
+		am.applyBoundProxy(acc)
+		am.unlock()
+	}
+	log.Printf("🔄 Account [%s] reloaded %s", acc.ID, filepath.Base(path))
+}
+
+// find ищет аккаунт по пути к файлу сессии. Вызывается под am.mu.
+func (am *AccountManager) find(sessionPath string) *Account {
+	for _, acc := range am.accounts {
+		if acc.SessionPath == sessionPath {
+			return acc
+		}
 	}
 	return nil
 }
+
+// prune удаляет из пула выведенные и забаненные аккаунты, которые больше
+// не заняты. Забаненные при этом уходят в карантин. Вызывается под am.mu.
+func (am *AccountManager) prune() {
+	accs := am.accounts[:0]
+	for _, acc := range am.accounts {
+		acc.lock.Lock()
+		retired := acc.Retired && !acc.InUse
+		banned := acc.IsBanned && !acc.InUse && am.quarantineDir != ""
+		acc.lock.Unlock()
+
+		switch {
+		case retired:
+			am.unbindProxy(acc)
+		case banned:
+			am.unbindProxy(acc)
+			am.quarantine(acc)
+		default:
+			accs = append(accs, acc)
+		}
+	}
+	am.accounts = accs
+}
+
+// quarantine переносит файлы аккаунта в карантин, если он включён.
+func (am *AccountManager) quarantine(acc *Account) {
+	if am.quarantineDir == "" {
+		return
+	}
+	if err := quarantine(acc, am.sessionDir, am.quarantineDir); err != nil {
+		log.Printf("⚠️ Cant quarantine [%s]: %v", acc.ID, err)
+		return
+	}
+	log.Printf("🚷 Account [%s] moved to quarantine: %s", acc.ID, acc.BanReason)
+	am.emit(acc, EventQuarantined, acc.BanReason)
+}
+
+// emit откладывает событие аккаунта до am.unlock. Вызывается под am.mu
+// или до того, как менеджер стал доступен другим горутинам.
+func (am *AccountManager) emit(acc *Account, t EventType, reason string) {
+	am.pending = append(am.pending, Event{Type: t, AccountID: acc.ID, Time: time.Now(), Reason: reason})
+}
+
+// unlock снимает am.mu и отправляет накопленные под ним события: подписчики
+// выполняются синхронно и не должны держать блокировку менеджера.
+func (am *AccountManager) unlock() {
+	events := am.pending
+	am.pending = nil
+	am.mu.Unlock()
+	for _, e := range events {
+		am.events.Publish(e)
+	}
+}
+
+// inQuarantine сообщает, лежит ли путь внутри директории карантина.
+func (am *AccountManager) inQuarantine(path string) bool {
+	return am.quarantineDir != "" && isWithin(am.quarantineDir, path)
+}
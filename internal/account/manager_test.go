@@ -0,0 +1,92 @@
+package account
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"tg-online-checker/internal/proxy"
+)
+
+func writeState(t *testing.T, acc *Account, state accountState) {
+	t.Helper()
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(acc.StatePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadStateIgnoresOwnWrites(t *testing.T) {
+	am := testManager()
+	acc := testAccount(t, "direct")
+	am.accounts = []*Account{acc}
+	if err := acc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ограничение поймано после записи, а событие о записи пришло позже
+	acc.FloodWait = 1 << 40
+	acc.IsBanned, acc.BanReason = true, "USER_DEACTIVATED"
+	am.ReloadSidecar(acc.StatePath)
+	if acc.FloodWait == 0 || !acc.IsBanned {
+		t.Fatal("reload of the manager's own write discarded newer in-memory state")
+	}
+
+	// правка оператора применяется
+	writeState(t, acc, accountState{ID: acc.ID})
+	am.ReloadSidecar(acc.StatePath)
+	if acc.FloodWait != 0 || acc.IsBanned {
+		t.Errorf("edited state not applied: flood wait %d, banned %v", acc.FloodWait, acc.IsBanned)
+	}
+}
+
+func TestReloadStateRebindsProxy(t *testing.T) {
+	first, _ := listenProxy(t)
+	second, ln := listenProxy(t)
+	pool := proxy.NewPool([]*proxy.Checked{{URL: first, Attempts: 1}, {URL: second, Attempts: 1}}, proxy.CheckOptions{}, 0)
+
+	am := testManager()
+	am.pool = pool
+	acc := testAccount(t, "pool")
+	am.accounts = []*Account{acc}
+	am.setTransport(acc, TransportPool, first)
+	if err := acc.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeState(t, acc, accountState{ID: acc.ID, Proxy: proxy.Key(second)})
+	am.ReloadSidecar(acc.StatePath)
+	if proxy.Key(acc.Proxy) != proxy.Key(second) {
+		t.Fatalf("account on %s, want %s", proxy.Key(acc.Proxy), proxy.Key(second))
+	}
+	if am.bindings[proxy.Key(first)] != 0 || am.bindings[proxy.Key(second)] != 1 {
+		t.Errorf("bindings = %v", am.bindings)
+	}
+	if !dialsThrough(t, acc.GetResolver(), ln) {
+		t.Error("resolver was not rebuilt for the new proxy")
+	}
+
+	// неизвестный прокси не применяется, а state возвращается к текущему
+	writeState(t, acc, accountState{ID: acc.ID, Proxy: "http://127.0.0.1:9"})
+	am.ReloadSidecar(acc.StatePath)
+	if proxy.Key(acc.Proxy) != proxy.Key(second) || acc.BoundProxy != proxy.Key(second) {
+		t.Errorf("account on %s bound to %s, want %s", proxy.Key(acc.Proxy), acc.BoundProxy, proxy.Key(second))
+	}
+	if acc.stateEdited() {
+		t.Error("state file was not restored to the actual binding")
+	}
+}
+
+// listenProxy возвращает URL прокси, слушающего на локальном порту.
+func listenProxy(t *testing.T) (*url.URL, net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &url.URL{Scheme: "http", Host: ln.Addr().String()}, ln
+}
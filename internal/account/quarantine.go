@@ -0,0 +1,212 @@
+package account
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sidecarExts — файлы, которые переносятся в карантин вместе с сессией.
+// .session идёт последним, чтобы при восстановлении watcher увидел
+// сессию уже с готовыми .json и .state.
+var sidecarExts = []string{".json", ".state", ".session"}
+
+// QuarantineEntry описывает аккаунт, выведенный в карантин.
+type QuarantineEntry struct {
+	// ID — путь сессии относительно директории карантина без расширения,
+	// например "123" или "team/123"; по нему аккаунт восстанавливается.
+	ID        string
+	Reason    string
+	RetiredAt time.Time
+	// SessionDir — директория, из которой сессия ушла в карантин.
+	SessionDir string
+}
+
+func reasonPath(dir, id string) string {
+	return filepath.Join(dir, filepath.FromSlash(id)+".reason")
+}
+
+// quarantineID возвращает ID аккаунта в карантине: путь сессии
+// относительно sessionDir без расширения. Так сессии с одинаковыми именами
+// из разных поддиректорий не затирают друг друга.
+func quarantineID(sessionDir string, acc *Account) string {
+	rel, err := filepath.Rel(sessionDir, acc.SessionPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return acc.ID
+	}
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// quarantine переносит файлы аккаунта в директорию карантина, сохраняя
+// путь относительно sessionDir, и записывает рядом файл с причиной.
+// Существующие файлы в карантине не перезаписываются.
+func quarantine(acc *Account, sessionDir, dir string) error {
+	id := quarantineID(sessionDir, acc)
+	target := filepath.Join(dir, filepath.FromSlash(id))
+	for _, ext := range append(sidecarExts, ".reason") {
+		if _, err := os.Stat(target + ext); err == nil {
+			return fmt.Errorf("%s already exists in quarantine", filepath.Base(target+ext))
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := acc.SaveState(); err != nil {
+		return err
+	}
+
+	acc.lock.Lock()
+	reason := acc.BanReason
+	acc.lock.Unlock()
+	if reason == "" {
+		reason = "unknown"
+	}
+
+	for _, ext := range sidecarExts {
+		src := sidecarPath(acc.SessionPath, ext)
+		if err := os.Rename(src, target+ext); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("move %s: %w", filepath.Base(src), err)
+		}
+	}
+
+	content := fmt.Sprintf("reason: %s\nretired_at: %s\nsession_dir: %s\n",
+		reason, time.Now().Format(time.RFC3339), filepath.Dir(acc.SessionPath))
+	return os.WriteFile(reasonPath(dir, id), []byte(content), 0644)
+}
+
+// Quarantined возвращает список аккаунтов в карантине, включая
+// поддиректории.
+func Quarantined(dir string) ([]QuarantineEntry, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".reason" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]QuarantineEntry, 0, len(paths))
+	for _, path := range paths {
+		entry, err := readReason(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readReason читает файл причины карантина.
+func readReason(dir, path string) (QuarantineEntry, error) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return QuarantineEntry{}, err
+	}
+	entry := QuarantineEntry{ID: filepath.ToSlash(strings.TrimSuffix(rel, ".reason"))}
+	file, err := os.Open(path)
+	if err != nil {
+		return entry, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "reason":
+			entry.Reason = value
+		case "retired_at":
+			entry.RetiredAt, _ = time.Parse(time.RFC3339, value)
+		case "session_dir":
+			entry.SessionDir = value
+		}
+	}
+	return entry, nil
+}
+
+// Restore возвращает аккаунт из карантина в директорию, из которой он туда
+// ушёл (session_dir в файле причины), и снимает с него отметку о бане. Если
+// директория не записана, аккаунт восстанавливается в sessionDir с тем же
+// относительным путём.
+func Restore(quarantineDir, sessionDir, id string) error {
+	id = filepath.ToSlash(filepath.Clean(filepath.FromSlash(id)))
+	if filepath.IsAbs(id) || id == ".." || strings.HasPrefix(id, "../") {
+		return fmt.Errorf("invalid account id %q", id)
+	}
+	source := filepath.Join(quarantineDir, filepath.FromSlash(id))
+	sessionPath := source + ".session"
+	if _, err := os.Stat(sessionPath); err != nil {
+		return fmt.Errorf("account %s not found in quarantine: %w", id, err)
+	}
+
+	entry, err := readReason(quarantineDir, reasonPath(quarantineDir, id))
+	if err != nil {
+		return err
+	}
+	targetDir := entry.SessionDir
+	if targetDir == "" {
+		targetDir = filepath.Join(sessionDir, filepath.Dir(filepath.FromSlash(id)))
+	}
+	target := filepath.Join(targetDir, filepath.Base(source))
+	if _, err := os.Stat(target + ".session"); err == nil {
+		return fmt.Errorf("account %s already exists in %s", id, targetDir)
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	if err := clearBan(source + ".state"); err != nil {
+		return err
+	}
+
+	for _, ext := range sidecarExts {
+		if err := os.Rename(source+ext, target+ext); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("move %s: %w", filepath.Base(source+ext), err)
+		}
+	}
+
+	if err := os.Remove(reasonPath(quarantineDir, id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// clearBan снимает бан в state файле, не трогая остальные поля.
+func clearBan(statePath string) error {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var state accountState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal state %s: %w", statePath, err)
+	}
+	state.IsBanned = false
+	state.BanReason = ""
+
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0644)
+}
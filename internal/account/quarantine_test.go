@@ -0,0 +1,88 @@
+package account
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// quarantineFixture создаёт файлы сессии в sessions/<rel>.
+func quarantineFixture(t *testing.T, sessions, rel string) *Account {
+	t.Helper()
+	path := filepath.Join(sessions, filepath.FromSlash(rel)+".session")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".session", ".json"} {
+		if err := os.WriteFile(sidecarPath(path, ext), []byte(rel+ext), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Account{
+		ID:          getID(path),
+		SessionPath: path,
+		StatePath:   sidecarPath(path, ".state"),
+		IsBanned:    true,
+		BanReason:   "USER_DEACTIVATED",
+	}
+}
+
+func TestQuarantineKeepsSubdirectories(t *testing.T) {
+	root := t.TempDir()
+	sessions := filepath.Join(root, "sessions")
+	dir := filepath.Join(root, "quarantine")
+
+	first := quarantineFixture(t, sessions, "team-a/123")
+	second := quarantineFixture(t, sessions, "team-b/123")
+	for _, acc := range []*Account{first, second} {
+		if err := quarantine(acc, sessions, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := Quarantined(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+		if e.Reason != "USER_DEACTIVATED" || e.SessionDir == "" {
+			t.Errorf("entry %s: reason %q, session dir %q", e.ID, e.Reason, e.SessionDir)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) != 2 || ids[0] != "team-a/123" || ids[1] != "team-b/123" {
+		t.Fatalf("quarantined = %q, want both sessions", ids)
+	}
+
+	// одноимённая сессия не затирает ту, что уже в карантине
+	again := quarantineFixture(t, sessions, "team-a/123")
+	if err := quarantine(again, sessions, dir); err == nil {
+		t.Error("quarantine overwrote an existing entry")
+	}
+
+	if err := Restore(dir, filepath.Join(root, "elsewhere"), "team-b/123"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(second.SessionPath)
+	if err != nil {
+		t.Fatalf("session not restored to its directory: %v", err)
+	}
+	if string(data) != "team-b/123.session" {
+		t.Errorf("restored the wrong session: %q", data)
+	}
+	if err := second.LoadState(); err != nil || second.IsBanned {
+		t.Errorf("ban not cleared on restore: err %v, banned %v", err, second.IsBanned)
+	}
+	if _, err := os.Stat(reasonPath(dir, "team-b/123")); !os.IsNotExist(err) {
+		t.Error("reason file left in quarantine")
+	}
+}
+
+func TestRestoreRejectsPathTraversal(t *testing.T) {
+	if err := Restore(t.TempDir(), t.TempDir(), "../outside"); err == nil {
+		t.Error("Restore accepted an id outside the quarantine")
+	}
+}
@@ -129,3 +129,24 @@ func SqiteSession(sessionPath string) (*session.Data, error) {
 		AuthKeyID: id[:],
 	}, nil
 }
+
+// SessionAddrs возвращает уникальные адреса DC из всех сессий директории —
+// по ним проверяются прокси перед запуском.
+func SessionAddrs(sessionDir string, skip ...string) []string {
+	paths, err := getSessionFiles(sessionDir, skip...)
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var addrs []string
+	for _, path := range paths {
+		data, err := SqiteSession(path)
+		if err != nil || seen[data.Addr] {
+			continue
+		}
+		seen[data.Addr] = true
+		addrs = append(addrs, data.Addr)
+	}
+	return addrs
+}
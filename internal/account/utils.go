@@ -4,15 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"tg-online-checker/internal/proxy"
 
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram/dcs"
-	"golang.org/x/net/proxy"
 )
 
 const (
@@ -31,10 +30,22 @@ type jsonData struct {
 	Data    session.Data
 }
 
-func getSessionFiles(sessionDir string) ([]string, error) {
+// getSessionFiles рекурсивно ищет .session файлы, пропуская директории skip.
+func getSessionFiles(sessionDir string, skip ...string) ([]string, error) {
 	sessionFiles := []string{}
 	err := filepath.Walk(sessionDir, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() && filepath.Ext(path) == ".session" {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			for _, dir := range skip {
+				if dir != "" && isWithin(dir, path) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if filepath.Ext(path) == ".session" {
 			sessionFiles = append(sessionFiles, path)
 		}
 		return nil
@@ -46,6 +57,25 @@ func getSessionFiles(sessionDir string) ([]string, error) {
 	return sessionFiles, nil
 }
 
+// isWithin сообщает, совпадает ли path с dir или лежит внутри неё.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// sidecarPath возвращает путь к сопутствующему файлу сессии (.json, .state).
+func sidecarPath(sessionPath, ext string) string {
+	return strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + ext
+}
+
+// sessionPathFor возвращает путь к .session файлу по пути сопутствующего файла.
+func sessionPathFor(path string) string {
+	return sidecarPath(path, ".session")
+}
+
 func getStorage(a *Account) (*session.StorageMemory, error) {
 	data, err := SqiteSession(a.SessionPath)
 	if err != nil {
@@ -67,45 +97,99 @@ func getStorage(a *Account) (*session.StorageMemory, error) {
 
 func getResolver(a *Account) (dcs.Resolver, error) {
 
-	auth := &proxy.Auth{
-		User:     a.Proxy.User.Username(),
-		Password: "",
+	if a.Proxy == nil {
+		return dcs.Plain(dcs.PlainOptions{}), nil
 	}
-	if pwd, ok := a.Proxy.User.Password(); ok {
-		auth.Password = pwd
+	if proxy.IsMTProxy(a.Proxy) {
+		secret, err := proxy.MTProxySecret(a.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		return dcs.MTProxy(a.Proxy.Host, secret, dcs.MTProxyOptions{})
 	}
 
-	dialer, err := proxy.SOCKS5("tcp", a.Proxy.Host, auth, proxy.Direct)
+	dialer, err := proxy.Dialer(a.Proxy)
 	if err != nil {
 		return nil, err
 	}
 
-	dialFunc := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		return dialer.Dial(network, addr)
+	return dcs.Plain(dcs.PlainOptions{Dial: dialer.DialContext}), nil
+
+}
+
+// readSidecarJSON читает .json файл сессии в map для обработки произвольных
+// ключей. Если файла нет, возвращает nil без ошибки.
+func readSidecarJSON(sessionPath string) (map[string]interface{}, error) {
+	file, err := os.Open(sidecarPath(sessionPath, ".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
 	}
+	return raw, nil
+}
 
-	return dcs.Plain(dcs.PlainOptions{Dial: dialFunc}), nil
+// getProxyOverride возвращает значение ключа "proxy" (или "transport")
+// из .json файла сессии.
+func getProxyOverride(sessionPath string) (string, error) {
+	raw, err := readSidecarJSON(sessionPath)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range raw {
+		switch normalizeKey(key) {
+		case "proxy", "transport":
+			s, ok := value.(string)
+			if !ok {
+				return "", fmt.Errorf("invalid proxy type for key %s: %T", key, value)
+			}
+			return s, nil
+		}
+	}
+	return "", nil
+}
 
+// getPhone возвращает номер телефона из ключа "phone" .json файла сессии.
+func getPhone(sessionPath string) (string, error) {
+	raw, err := readSidecarJSON(sessionPath)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range raw {
+		switch normalizeKey(key) {
+		case "phone", "phonenumber":
+			switch v := value.(type) {
+			case string:
+				return v, nil
+			case float64:
+				return strconv.FormatInt(int64(v), 10), nil
+			default:
+				return "", fmt.Errorf("invalid phone type for key %s: %T", key, v)
+			}
+		}
+	}
+	return "", nil
 }
 
 // loadAppCredentials загружает app_id и app_hash из JSON файла
 func getAppCredentials(sessionPath string) (int, string, error) {
 
-	jsonPath := strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + ".json"
-	// Открываем файл
-	file, err := os.Open(jsonPath)
+	raw, err := readSidecarJSON(sessionPath)
 	if err != nil {
+		return 0, "", err
+	}
+	if raw == nil {
 		// Возвращаем значения по умолчанию, если файл не найден
 		return defaultAppID, defaultAppHash, nil
 	}
-	defer file.Close()
-
-	// Декодируем JSON в map для обработки произвольных ключей
-	var raw map[string]interface{}
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&raw); err != nil {
-		return 0, "", fmt.Errorf("failed to decode JSON: %w", err)
-	}
 
 	// Ищем app_id и app_hash, игнорируя регистр и подчеркивания
 	var appID int
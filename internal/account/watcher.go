@@ -0,0 +1,119 @@
+package account
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce — пауза после последнего события по файлу, прежде чем
+// его обработать: файлы сессий часто копируются в несколько записей.
+const watchDebounce = 2 * time.Second
+
+// Watch следит за директорией сессий и обновляет пул аккаунтов на лету:
+// новые .session добавляются, удалённые выводятся из пула после завершения
+// текущей задачи, изменённые .json/.state перечитываются.
+// Блокируется до отмены контекста.
+func (am *AccountManager) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	err = filepath.Walk(am.sessionDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if am.inQuarantine(path) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu      sync.Mutex
+		pending = map[string]*time.Timer{}
+	)
+	schedule := func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if t, ok := pending[path]; ok {
+			t.Reset(watchDebounce)
+			return
+		}
+		pending[path] = time.AfterFunc(watchDebounce, func() {
+			mu.Lock()
+			delete(pending, path)
+			mu.Unlock()
+			am.handleFileChange(path)
+		})
+	}
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, t := range pending {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if am.inQuarantine(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						log.Printf("[watcher] cant watch %s: %v", event.Name, err)
+					}
+					continue
+				}
+			}
+			switch filepath.Ext(event.Name) {
+			case ".session", ".json", ".state":
+				schedule(event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("[watcher] error: %v", err)
+		}
+	}
+}
+
+// handleFileChange применяет к пулу итоговое состояние файла после серии событий.
+func (am *AccountManager) handleFileChange(path string) {
+	_, err := os.Stat(path)
+	exists := err == nil
+
+	switch filepath.Ext(path) {
+	case ".session":
+		if !exists {
+			am.RetireSession(path)
+			return
+		}
+		if err := am.AddSession(path); err != nil {
+			log.Printf("⚠️ Cant add session %s: %v", path, err)
+		}
+	case ".json", ".state":
+		if exists {
+			am.ReloadSidecar(path)
+		}
+	}
+}
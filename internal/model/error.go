@@ -0,0 +1,9 @@
+package model
+
+// CheckError — запись о задаче, которую не удалось выполнить.
+type CheckError struct {
+	Username  string
+	AccountID string
+	Error     string
+	CheckedAt int64
+}
@@ -9,7 +9,13 @@ type User struct {
 	FirstName string
 	LastName  string
 	Premium   bool
+	// Status — статус из профиля (см. Status*). WasOnline известен только
+	// для StatusOffline.
+	Status    string
 	WasOnline int
+	// AccountID и CheckedAt — каким аккаунтом и когда (Unix) получены данные.
+	AccountID string
+	CheckedAt int64
 }
 
 func NewUser(tgUser *tg.User) *User {
@@ -22,11 +28,36 @@ func NewUser(tgUser *tg.User) *User {
 		Premium:   tgUser.Premium,
 	}
 
-	if status, ok := tgUser.Status.(*tg.UserStatusOffline); ok {
+	switch status := tgUser.Status.(type) {
+	case *tg.UserStatusOnline:
+		user.Status = StatusOnline
+	case *tg.UserStatusOffline:
+		user.Status = StatusOffline
 		user.WasOnline = status.WasOnline
-	} else {
-		user.WasOnline = 0
+	case *tg.UserStatusRecently:
+		user.Status = StatusRecently
+	case *tg.UserStatusLastWeek:
+		user.Status = StatusLastWeek
+	case *tg.UserStatusLastMonth:
+		user.Status = StatusLastMonth
+	default:
+		user.Status = StatusHidden
 	}
 
 	return user
 }
+
+// Статусы пользователя в User.Status.
+const (
+	// StatusOnline — пользователь в сети сейчас.
+	StatusOnline = "online"
+	// StatusOffline — не в сети, время последнего визита в WasOnline.
+	StatusOffline = "offline"
+	// StatusRecently, StatusLastWeek, StatusLastMonth — точное время скрыто,
+	// Telegram сообщает только примерный период.
+	StatusRecently  = "recently"
+	StatusLastWeek  = "last_week"
+	StatusLastMonth = "last_month"
+	// StatusHidden — статус неизвестен или скрыт полностью.
+	StatusHidden = "hidden"
+)
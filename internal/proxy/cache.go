@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	Alive     bool            `json:"alive"`
+	LatencyMs int64           `json:"latency_ms"`
+	Attempts  int             `json:"attempts"`
+	Failures  int             `json:"failures"`
+	Error     string          `json:"error,omitempty"`
+	EgressIP  string          `json:"egress_ip,omitempty"`
+	Country   string          `json:"country,omitempty"`
+	Targets   string          `json:"targets"`
+	Reached   map[string]bool `json:"reached,omitempty"`
+	CheckedAt time.Time       `json:"checked_at"`
+}
+
+// Cache хранит результаты проверки прокси на диске, чтобы при следующем
+// запуске не перепроверять те, что проверялись недавно. Результат считается
+// свежим, пока не истёк TTL и не изменился набор адресов проверки.
+// Методы безопасны для nil: кеш просто отключён.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// OpenCache загружает кеш из файла. Отсутствующий или повреждённый файл —
+// пустой кеш, который перезапишет файл при следующем Save.
+func OpenCache(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{path: path, ttl: ttl, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		log.Printf("⚠️ Proxy check cache %s is corrupt, rebuilding: %v", path, err)
+		c.entries = map[string]cacheEntry{}
+		c.dirty = true
+	}
+	return c, nil
+}
+
+// cacheKey — ключ записи кеша: Key прокси и хеш полного URL. Результат
+// проверки зависит от пароля и секрета MTProxy, но сами они в файл кеша
+// не попадают.
+func cacheKey(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	return Key(u) + "#" + hex.EncodeToString(sum[:8])
+}
+
+// Get возвращает свежий результат проверки прокси, если он есть.
+func (c *Cache) Get(u *url.URL, targets []string) (*Checked, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[cacheKey(u)]
+	if !ok || time.Since(e.CheckedAt) > c.ttl || e.Targets != strings.Join(targets, ",") {
+		return nil, false
+	}
+
+	checked := &Checked{
+		URL:       u,
+		Latency:   time.Duration(e.LatencyMs) * time.Millisecond,
+		Attempts:  e.Attempts,
+		Failures:  e.Failures,
+		CheckedAt: e.CheckedAt,
+		EgressIP:  e.EgressIP,
+		Country:   e.Country,
+		Reached:   e.Reached,
+	}
+	if e.Error != "" {
+		checked.LastError = errors.New(e.Error)
+	}
+	return checked, true
+}
+
+// Put сохраняет результат проверки в кеш (в памяти; на диск — в Save).
+func (c *Cache) Put(checked *Checked, targets []string) {
+	if c == nil {
+		return
+	}
+	e := cacheEntry{
+		Alive:     checked.Alive(),
+		LatencyMs: checked.Latency.Milliseconds(),
+		Attempts:  checked.Attempts,
+		Failures:  checked.Failures,
+		EgressIP:  checked.EgressIP,
+		Country:   checked.Country,
+		Targets:   strings.Join(targets, ","),
+		Reached:   checked.Reached,
+		CheckedAt: checked.CheckedAt,
+	}
+	if checked.LastError != nil {
+		e.Error = checked.LastError.Error()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey(checked.URL)] = e
+	c.dirty = true
+}
+
+// Save записывает кеш на диск, если он изменился. Устаревшие записи
+// при этом удаляются.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	for key, e := range c.entries {
+		if time.Since(e.CheckedAt) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer возвращает диалер для прокси, выбранный по схеме URL:
+// socks5/socks5h, socks4/socks4a, http (CONNECT) и https (CONNECT поверх TLS).
+// Один и тот же диалер используется и при проверке прокси, и воркерами.
+func Dialer(u *url.URL) (proxy.ContextDialer, error) {
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			auth = &proxy.Auth{User: u.User.Username()}
+			auth.Password, _ = u.User.Password()
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks5 dialer does not support context")
+		}
+		return cd, nil
+	case "socks4", "socks4a":
+		return &socks4Dialer{addr: u.Host, user: u.User.Username(), resolveRemote: strings.EqualFold(u.Scheme, "socks4a")}, nil
+	case "http", "https":
+		d := &httpConnectDialer{addr: u.Host, useTLS: strings.EqualFold(u.Scheme, "https")}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			d.auth = base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		}
+		if d.useTLS {
+			d.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+// HandshakeError — прокси не смог установить туннель: отказал в CONNECT,
+// не прошёл TLS или ответил не по протоколу.
+type HandshakeError struct {
+	Err error
+}
+
+func (e *HandshakeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransportError сообщает, что ошибка возникла при подключении через
+// прокси или при передаче данных: ошибка сети, рукопожатия с прокси или
+// оборванное соединение. RPC ошибки Telegram и ошибки приложения сюда не
+// относятся — они ничего не говорят о прокси.
+func IsTransportError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var (
+		netErr       net.Error
+		handshakeErr *HandshakeError
+	)
+	return errors.As(err, &netErr) ||
+		errors.As(err, &handshakeErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// withHandshakeDeadline выставляет на время рукопожатия с прокси дедлайн
+// из контекста и снимает его по завершении.
+func withHandshakeDeadline(ctx context.Context, conn net.Conn, handshake func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0)) // прерываем зависшее чтение
+		case <-done:
+		}
+	}()
+
+	err := handshake()
+	close(done)
+	// горутина могла выставить дедлайн уже после рукопожатия — дожидаемся
+	// её и только потом снимаем дедлайн, иначе соединение останется сломанным
+	<-stopped
+	conn.SetDeadline(time.Time{})
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &HandshakeError{Err: err}
+	}
+	return nil
+}
+
+// httpConnectDialer устанавливает туннель через HTTP прокси методом CONNECT.
+type httpConnectDialer struct {
+	addr      string
+	auth      string
+	useTLS    bool
+	tlsConfig *tls.Config
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var result net.Conn
+	err = withHandshakeDeadline(ctx, conn, func() error {
+		c := conn
+		if d.useTLS {
+			tlsConn := tls.Client(conn, d.tlsConfig)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				return fmt.Errorf("tls handshake with proxy: %w", err)
+			}
+			c = tlsConn
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if d.auth != "" {
+			req.Header.Set("Proxy-Authorization", "Basic "+d.auth)
+		}
+		if err := req.Write(c); err != nil {
+			return fmt.Errorf("write CONNECT: %w", err)
+		}
+
+		br := bufio.NewReader(c)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			return fmt.Errorf("read CONNECT response: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+		}
+
+		result = c
+		if br.Buffered() > 0 {
+			result = &bufferedConn{Conn: c, r: br}
+		}
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return result, nil
+}
+
+// bufferedConn отдаёт данные, прочитанные прокси-ответом в буфер сверх заголовков.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// socks4Dialer реализует SOCKS4 и SOCKS4a (с резолвом имени на стороне прокси).
+type socks4Dialer struct {
+	addr          string
+	user          string
+	resolveRemote bool
+}
+
+func (d *socks4Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	req := []byte{4, 1, 0, 0, 0, 0, 0, 1}
+	binary.BigEndian.PutUint16(req[2:4], uint16(port))
+	var hostname string
+	if ip := net.ParseIP(host).To4(); ip != nil {
+		copy(req[4:8], ip)
+	} else if d.resolveRemote {
+		hostname = host // 0.0.0.1 в поле IP означает, что имя передаётся ниже
+	} else {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no IPv4 address for %s", host)
+		}
+		copy(req[4:8], ips[0].To4())
+	}
+	req = append(req, d.user...)
+	req = append(req, 0)
+	if hostname != "" {
+		req = append(req, hostname...)
+		req = append(req, 0)
+	}
+
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withHandshakeDeadline(ctx, conn, func() error {
+		if _, err := conn.Write(req); err != nil {
+			return fmt.Errorf("write socks4 request: %w", err)
+		}
+		var resp [8]byte
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return fmt.Errorf("read socks4 response: %w", err)
+		}
+		if resp[1] != 90 {
+			return errors.New("socks4 request rejected, code " + strconv.Itoa(int(resp[1])))
+		}
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
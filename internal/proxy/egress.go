@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// CheckEgress запрашивает через прокси IP-echo сервис и возвращает внешний
+// IP, с которым прокси выходит в интернет. Сервис может отвечать текстом
+// с адресом или JSON с полем "ip" (ipify, ifconfig.co и т.п.).
+func CheckEgress(ctx context.Context, u *url.URL, echoURL string, timeout time.Duration) (net.IP, error) {
+	if IsMTProxy(u) {
+		return nil, fmt.Errorf("egress check is not supported for MTProxy")
+	}
+	dialer, err := Dialer(u)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:       nil,
+			DialContext: dialer.DialContext,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, echoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("echo service returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return nil, err
+	}
+	return parseEchoIP(body)
+}
+
+func parseEchoIP(body []byte) (net.IP, error) {
+	text := strings.TrimSpace(string(body))
+	if ip := net.ParseIP(text); ip != nil {
+		return ip, nil
+	}
+
+	var obj struct {
+		IP     string `json:"ip"`
+		Origin string `json:"origin"`
+	}
+	if err := json.Unmarshal(body, &obj); err == nil {
+		for _, s := range []string{obj.IP, obj.Origin} {
+			if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+				return ip, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no IP address in echo response")
+}
+
+// GeoDB определяет страну IP адреса по офлайн базе MaxMind (GeoLite2/GeoIP2
+// Country или City в формате .mmdb).
+type GeoDB struct {
+	reader *geoip2.Reader
+}
+
+func OpenGeoDB(path string) (*GeoDB, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoDB{reader: reader}, nil
+}
+
+// Country возвращает ISO код страны IP адреса или пустую строку.
+func (g *GeoDB) Country(ip net.IP) string {
+	if g == nil || ip == nil {
+		return ""
+	}
+	record, err := g.reader.Country(ip)
+	if err != nil {
+		return ""
+	}
+	return record.Country.IsoCode
+}
+
+func (g *GeoDB) Close() error {
+	return g.reader.Close()
+}
+
+// checkEgress дополняет результат проверки живого прокси внешним IP и
+// страной, если в opts задан IP-echo сервис.
+func checkEgress(ctx context.Context, result *Checked, opts CheckOptions) {
+	if opts.EgressURL == "" || !result.Alive() || IsMTProxy(result.URL) {
+		return
+	}
+	ip, err := CheckEgress(ctx, result.URL, opts.EgressURL, opts.Timeout)
+	if err != nil {
+		fmt.Printf("⚠️ Proxy %s: egress check failed: %v\n", ProxyString(result.URL), err)
+		return
+	}
+	result.EgressIP = ip.String()
+	result.Country = opts.GeoDB.Country(ip)
+}
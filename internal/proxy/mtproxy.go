@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gotd/td/mtproxy"
+)
+
+// mtproxyScheme — схема, которой внутри программы обозначается MTProxy:
+// mtproxy://host:port?secret=<hex>.
+const mtproxyScheme = "mtproxy"
+
+// IsMTProxy сообщает, что URL описывает MTProxy, а не SOCKS/HTTP прокси.
+func IsMTProxy(u *url.URL) bool {
+	return u != nil && u.Scheme == mtproxyScheme
+}
+
+// MTProxySecret возвращает декодированный секрет MTProxy из URL.
+func MTProxySecret(u *url.URL) ([]byte, error) {
+	return decodeMTProxySecret(u.Query().Get("secret"))
+}
+
+// isMTProxyLink распознаёт ссылки tg://proxy?... и https://t.me/proxy?...
+func isMTProxyLink(line string) bool {
+	lower := strings.ToLower(line)
+	return strings.HasPrefix(lower, "tg://proxy?") ||
+		strings.HasPrefix(lower, "https://t.me/proxy?") ||
+		strings.HasPrefix(lower, "http://t.me/proxy?") ||
+		strings.HasPrefix(lower, "t.me/proxy?")
+}
+
+// parseMTProxyLink разбирает ссылку вида tg://proxy?server=..&port=..&secret=..
+func parseMTProxyLink(line string) (Proxy, error) {
+	_, rawQuery, _ := strings.Cut(line, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Proxy{}, fmt.Errorf("invalid MTProxy link: %v", err)
+	}
+
+	p := Proxy{
+		Scheme: mtproxyScheme,
+		Host:   strings.Trim(q.Get("server"), "[]"),
+		Port:   q.Get("port"),
+		Secret: q.Get("secret"),
+	}
+	if err := validateHostPort(p.Host, p.Port); err != nil {
+		return Proxy{}, err
+	}
+
+	secret, err := decodeMTProxySecret(p.Secret)
+	if err != nil {
+		return Proxy{}, err
+	}
+	if _, err := mtproxy.ParseSecret(secret); err != nil {
+		return Proxy{}, fmt.Errorf("invalid MTProxy secret: %v", err)
+	}
+	// Храним секрет в hex, чтобы URL не зависел от формата исходной ссылки.
+	p.Secret = hex.EncodeToString(secret)
+	return p, nil
+}
+
+// decodeMTProxySecret понимает hex и base64 (обычный и url-safe) секреты.
+func decodeMTProxySecret(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty MTProxy secret")
+	}
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	for _, enc := range []*base64.Encoding{
+		base64.RawURLEncoding, base64.URLEncoding,
+		base64.RawStdEncoding, base64.StdEncoding,
+	} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("MTProxy secret is neither hex nor base64")
+}
+
+// mtproxyURL собирает внутренний URL MTProxy.
+func mtproxyURL(p Proxy) *url.URL {
+	return &url.URL{
+		Scheme:   mtproxyScheme,
+		Host:     net.JoinHostPort(p.Host, p.Port),
+		RawQuery: url.Values{"secret": {p.Secret}}.Encode(),
+	}
+}
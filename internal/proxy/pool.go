@@ -0,0 +1,349 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultMaxFailures — сколько подряд неудачных подключений воркеров
+// переводят прокси в нерабочие.
+const DefaultMaxFailures = 3
+
+type poolEntry struct {
+	checked *Checked
+	// source — источник, из которого пришёл прокси (Source.String()); пусто,
+	// если прокси добавлен через Add и не привязан к источнику.
+	source      string
+	healthy     bool
+	consecutive int
+}
+
+// Pool хранит проверенные прокси и следит за их состоянием во время работы:
+// периодически перепроверяет их и учитывает ошибки подключения, о которых
+// сообщают воркеры. Когда прокси признан нерабочим, вызываются обработчики
+// OnDown, чтобы аккаунты могли переехать на запасной прокси.
+type Pool struct {
+	mu          sync.Mutex
+	entries     []*poolEntry
+	opts        CheckOptions
+	maxFailures int
+	onDown      []func(u *url.URL, reason string)
+}
+
+// NewPool создаёт пул из результатов проверки (см. Load). opts задают,
+// как прокси перепроверяются в Run и проверяются при пополнении.
+func NewPool(checked []*Checked, opts CheckOptions, maxFailures int) *Pool {
+	if maxFailures <= 0 {
+		maxFailures = DefaultMaxFailures
+	}
+	opts.setDefaults()
+	p := &Pool{opts: opts, maxFailures: maxFailures}
+	p.Add(checked)
+	return p
+}
+
+// OnDown добавляет обработчик, который вызывается, когда прокси признан нерабочим.
+func (p *Pool) OnDown(handler func(u *url.URL, reason string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onDown = append(p.onDown, handler)
+}
+
+// URLs возвращает рабочие прокси, лучшие первыми.
+func (p *Pool) URLs() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var urls []*url.URL
+	for _, e := range p.entries {
+		if e.healthy {
+			urls = append(urls, e.checked.URL)
+		}
+	}
+	return urls
+}
+
+// Country возвращает страну внешнего IP прокси, если она известна.
+func (p *Pool) Country(u *url.URL) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.find(u); e != nil {
+		return e.checked.Country
+	}
+	return ""
+}
+
+// Reaches сообщает, доступен ли addr через прокси по последней проверке
+// (см. Checked.Reaches). Пустой addr — достаточно, что прокси в пуле.
+func (p *Pool) Reaches(u *url.URL, addr string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.find(u)
+	return e != nil && (addr == "" || e.checked.Reaches(addr))
+}
+
+// Len возвращает общее число прокси в пуле, включая нерабочие.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// ReportSuccess сбрасывает счётчик ошибок прокси после удачного подключения.
+func (p *Pool) ReportSuccess(u *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.find(u); e != nil {
+		e.consecutive = 0
+	}
+}
+
+// ReportFailure учитывает ошибку подключения через прокси. После
+// maxFailures ошибок подряд прокси признаётся нерабочим.
+func (p *Pool) ReportFailure(u *url.URL, err error) {
+	p.mu.Lock()
+	e := p.find(u)
+	if e == nil || !e.healthy {
+		p.mu.Unlock()
+		return
+	}
+	e.consecutive++
+	if e.consecutive < p.maxFailures {
+		p.mu.Unlock()
+		return
+	}
+	e.healthy = false
+	reason := fmt.Sprintf("%d connection failures in a row, last: %v", e.consecutive, err)
+	handlers := p.onDown
+	p.mu.Unlock()
+
+	p.notifyDown(handlers, u, reason)
+}
+
+// Add добавляет в пул живые прокси, которых в нём ещё нет.
+// Возвращает число добавленных.
+func (p *Pool) Add(checked []*Checked) int {
+	return p.add("", checked)
+}
+
+// AddSource добавляет живые прокси, полученные из src (см. Load). Такие
+// прокси убираются из пула, когда пропадают из списка источника при Refill.
+func (p *Pool) AddSource(src Source, checked []*Checked) int {
+	return p.add(src.String(), checked)
+}
+
+func (p *Pool) add(source string, checked []*Checked) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	added := 0
+	for _, c := range checked {
+		if !c.Alive() || p.find(c.URL) != nil {
+			continue
+		}
+		p.entries = append(p.entries, &poolEntry{checked: c, source: source, healthy: true})
+		added++
+	}
+	p.sort()
+	return added
+}
+
+// Refill получает список из источника и приводит к нему прокси этого
+// источника в пуле: новые проверяются и живые добавляются, а прокси,
+// которых больше нет в списке, убираются. Уже известные прокси не
+// перепроверяются.
+func (p *Pool) Refill(ctx context.Context, src Source) error {
+	proxies, err := src.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(proxies))
+	for _, px := range proxies {
+		listed[px.URL().String()] = true
+	}
+	if removed := p.removeUnlisted(src.String(), listed); removed > 0 {
+		log.Printf("[proxy pool] %s: removed %d proxies no longer listed", src, removed)
+	}
+
+	p.mu.Lock()
+	fresh := proxies[:0]
+	for _, px := range proxies {
+		if p.find(px.URL()) == nil {
+			fresh = append(fresh, px)
+		}
+	}
+	p.mu.Unlock()
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	checked, err := checkProxies(ctx, fresh, p.opts)
+	if err != nil {
+		return err
+	}
+	if added := p.AddSource(src, checked); added > 0 {
+		log.Printf("[proxy pool] %s: added %d new proxies", src, added)
+	}
+	return nil
+}
+
+// removeUnlisted убирает из пула прокси источника source, которых нет в
+// listed. Аккаунты на убранных рабочих прокси переезжают через OnDown.
+// Возвращает число убранных.
+func (p *Pool) removeUnlisted(source string, listed map[string]bool) int {
+	p.mu.Lock()
+	var (
+		kept []*poolEntry
+		down []*url.URL
+	)
+	for _, e := range p.entries {
+		if e.source != source || listed[e.checked.URL.String()] {
+			kept = append(kept, e)
+			continue
+		}
+		if e.healthy {
+			down = append(down, e.checked.URL)
+		}
+	}
+	removed := len(p.entries) - len(kept)
+	p.entries = kept
+	handlers := p.onDown
+	p.mu.Unlock()
+
+	for _, u := range down {
+		p.notifyDown(handlers, u, "removed from source")
+	}
+	return removed
+}
+
+// RunSource пополняет пул из источника каждые interval, пока не отменён контекст.
+// interval <= 0 отключает пополнение: RunSource сразу возвращается.
+func (p *Pool) RunSource(ctx context.Context, src Source, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refill(ctx, src); err != nil {
+				log.Printf("[proxy pool] %s: refill failed: %v", src, err)
+			}
+		}
+	}
+}
+
+// Run перепроверяет все прокси пула каждые interval, пока не отменён контекст.
+// Упавшие прокси выводятся из ротации, восстановившиеся — возвращаются.
+// interval <= 0 отключает перепроверку: Run сразу возвращается.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Recheck(ctx)
+		}
+	}
+}
+
+// Recheck однократно перепроверяет все прокси пула.
+func (p *Pool) Recheck(ctx context.Context) {
+	p.mu.Lock()
+	entries := append([]*poolEntry{}, p.entries...)
+	p.mu.Unlock()
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, p.opts.Concurrency)
+	)
+	for _, e := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := checkProxy(ctx, e.checked.URL, p.opts)
+			checkEgress(ctx, result, p.opts)
+			p.apply(e, result)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.sort()
+	p.mu.Unlock()
+}
+
+// apply обновляет запись пула результатом перепроверки.
+func (p *Pool) apply(e *poolEntry, result *Checked) {
+	p.mu.Lock()
+	wasHealthy := e.healthy
+	e.checked = result
+	e.healthy = result.Alive()
+	if e.healthy {
+		e.consecutive = 0
+	}
+	handlers := p.onDown
+	p.mu.Unlock()
+
+	switch {
+	case wasHealthy && !e.healthy:
+		p.notifyDown(handlers, result.URL, fmt.Sprintf("recheck failed: %v", result.LastError))
+	case !wasHealthy && e.healthy:
+		log.Printf("[proxy pool] %s is back, latency %v", ProxyString(result.URL), result.Latency.Round(time.Millisecond))
+	}
+}
+
+func (p *Pool) notifyDown(handlers []func(*url.URL, string), u *url.URL, reason string) {
+	log.Printf("[proxy pool] %s is down: %s", ProxyString(u), reason)
+	for _, h := range handlers {
+		h(u, reason)
+	}
+}
+
+// find ищет запись по URL. Вызывается под p.mu.
+func (p *Pool) find(u *url.URL) *poolEntry {
+	if u == nil {
+		return nil
+	}
+	for _, e := range p.entries {
+		if e.checked.URL == u || e.checked.URL.String() == u.String() {
+			return e
+		}
+	}
+	return nil
+}
+
+// sort упорядочивает записи по оценке последней проверки. Вызывается под p.mu.
+func (p *Pool) sort() {
+	checked := make([]*Checked, len(p.entries))
+	byChecked := make(map[*Checked]*poolEntry, len(p.entries))
+	for i, e := range p.entries {
+		checked[i] = e.checked
+		byChecked[e.checked] = e
+	}
+	sortChecked(checked)
+	for i, c := range checked {
+		p.entries[i] = byChecked[c]
+	}
+}
+
+// ProxyString возвращает адрес прокси без учётных данных — для логов.
+func ProxyString(u *url.URL) string {
+	if u == nil {
+		return "direct"
+	}
+	return u.Scheme + "://" + u.Host
+}
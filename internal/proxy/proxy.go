@@ -1,97 +1,176 @@
 package proxy
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
-	"strings"
 	"sync"
 	"time"
-
-	"golang.org/x/net/proxy"
 )
 
 // Proxy представляет данные прокси.
 type Proxy struct {
-	IP       string
+	Scheme   string
+	Host     string
 	Port     string
 	Login    string
 	Password string
+	Secret   string // только для MTProxy
 }
 
-func checkProxy(ctx context.Context, p Proxy, testURL string) (*url.URL, error) {
-	// Формируем URL прокси.
-	proxyURLStr := fmt.Sprintf("socks5://%s:%s@%s:%s", p.Login, p.Password, p.IP, p.Port)
-	proxyURL, err := url.Parse(proxyURLStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy URL format: %v", err)
+// URL возвращает прокси в виде URL для диалера.
+func (p Proxy) URL() *url.URL {
+	if p.Scheme == mtproxyScheme {
+		return mtproxyURL(p)
+	}
+	u := &url.URL{
+		Scheme: p.Scheme,
+		Host:   net.JoinHostPort(p.Host, p.Port),
 	}
+	if p.Login != "" || p.Password != "" {
+		u.User = url.UserPassword(p.Login, p.Password)
+	}
+	return u
+}
 
-	// Создаем контекст с таймаутом.
-	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+// String возвращает адрес прокси без учётных данных — для логов.
+func (p Proxy) String() string {
+	return p.Scheme + "://" + net.JoinHostPort(p.Host, p.Port)
+}
 
-	// Создаем SOCKS5 диалер.
-	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create dialer: %v", err)
+// Key возвращает устойчивый идентификатор прокси: схема, логин и адрес,
+// без пароля и секрета. Используется для привязки прокси к аккаунтам.
+func Key(u *url.URL) string {
+	if u == nil {
+		return ""
 	}
-
-	// Используем DialContext для учета таймаута.
-	conn, err := dialer.(proxy.ContextDialer).DialContext(dialCtx, "tcp", testURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect via proxy: %v", err)
+	key := &url.URL{Scheme: u.Scheme, Host: u.Host}
+	if u.User != nil {
+		key.User = url.User(u.User.Username())
 	}
-	conn.Close()
+	return key.String()
+}
 
-	return proxyURL, nil
+// CheckOptions — параметры проверки прокси.
+type CheckOptions struct {
+	// Targets — адреса, с которыми проверяется соединение (см. DCTargets).
+	Targets []string
+	// Concurrency — сколько прокси проверяется одновременно.
+	Concurrency int
+	// Timeout — таймаут одного соединения.
+	Timeout time.Duration
+	// Cache, если задан, позволяет не перепроверять недавно проверенные прокси.
+	Cache *Cache
+	// EgressURL — IP-echo сервис для определения внешнего IP прокси.
+	// Пустая строка отключает проверку.
+	EgressURL string
+	// GeoDB определяет страну внешнего IP; nil — страна не определяется.
+	GeoDB *GeoDB
 }
 
-// readProxies читает прокси из файла.
-func readProxies(filePath string) ([]Proxy, error) {
-	file, err := os.Open(filePath)
+func (o *CheckOptions) setDefaults() {
+	if len(o.Targets) == 0 {
+		o.Targets = DCTargets()
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 10
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+}
+
+// checkProxy проверяет прокси соединением с каждым адресом из opts.Targets.
+// Адреса проверяются параллельно, поэтому проверка занимает не дольше
+// одного таймаута.
+func checkProxy(ctx context.Context, proxyURL *url.URL, opts CheckOptions) *Checked {
+	result := &Checked{URL: proxyURL, CheckedAt: time.Now()}
+
+	// MTProxy нельзя использовать как обычный туннель: проверяем, что
+	// сервер принимает соединения, а секрет валиден.
+	if IsMTProxy(proxyURL) {
+		var d net.Dialer
+		latency, err := measureDial(ctx, d.DialContext, proxyURL.Host, opts.Timeout)
+		result.record("", latency, err)
+		return result
+	}
+
+	// Создаем диалер по схеме прокси — тот же, что используют воркеры.
+	dialer, err := Dialer(proxyURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+		result.record("", 0, fmt.Errorf("failed to create dialer: %v", err))
+		return result
 	}
-	defer file.Close()
 
-	var proxies []Proxy
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, target := range opts.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := measureDial(ctx, dialer.DialContext, target, opts.Timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			result.record(target, latency, err)
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
 
-		parts := strings.Split(line, ":")
-		if len(parts) != 4 {
-			fmt.Printf("Skipping invalid line: %s\n", line)
-			continue
-		}
+// measureDial открывает соединение с addr и возвращает время установки.
+func measureDial(ctx context.Context, dial func(ctx context.Context, network, addr string) (net.Conn, error), addr string, timeout time.Duration) (time.Duration, error) {
+	// Создаем контекст с таймаутом.
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-		proxies = append(proxies, Proxy{
-			IP:       parts[0],
-			Port:     parts[1],
-			Login:    parts[2],
-			Password: parts[3],
-		})
+	start := time.Now()
+	conn, err := dial(dialCtx, "tcp", addr)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", addr, err)
 	}
+	latency := time.Since(start)
+	conn.Close()
+	return latency, nil
+}
+
+// lineError описывает строку файла прокси, которую не удалось разобрать.
+type lineError struct {
+	Line int
+	Err  error
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+func (e lineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// readProxies читает прокси из файла. Строки, которые не удалось
+// разобрать, возвращаются отдельным списком ошибок.
+func readProxies(filePath string) ([]Proxy, []lineError, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %v", err)
 	}
+	defer file.Close()
 
-	return proxies, nil
+	return scanProxies(file)
 }
 
-// checkProxies проверяет список прокси в многопоточном режиме.
-func checkProxies(proxies []Proxy, testURL string, workers int) ([]*url.URL, error) {
+// checkProxies проверяет список прокси в многопоточном режиме и возвращает
+// живые прокси, отсортированные по оценке (лучшие первыми). Прокси со
+// свежим результатом в кеше не перепроверяются.
+func checkProxies(ctx context.Context, proxies []Proxy, opts CheckOptions) ([]*Checked, error) {
+	opts.setDefaults()
 	var (
-		wg          sync.WaitGroup
-		mu          sync.Mutex
-		workingURLs []*url.URL
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		working []*Checked
+		cached  int
 	)
 
 	// Канал для передачи задач.
@@ -102,19 +181,35 @@ func checkProxies(proxies []Proxy, testURL string, workers int) ([]*url.URL, err
 	close(proxyChan)
 
 	// Запускаем воркеры.
-	for i := 0; i < workers; i++ {
+	for i := 0; i < opts.Concurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for p := range proxyChan {
-				proxyURL, err := checkProxy(context.Background(), p, testURL)
-				if err != nil {
-					fmt.Printf("🚫 Proxy %s:%s: %v\n", p.IP, p.Port, err)
+				result, ok := opts.Cache.Get(p.URL(), opts.Targets)
+				if ok && opts.EgressURL != "" && result.Alive() && result.EgressIP == "" && !IsMTProxy(result.URL) {
+					// в кеше нет внешнего IP — проверка была без egress
+					ok = false
+				}
+				if ok {
+					mu.Lock()
+					cached++
+					mu.Unlock()
+				} else {
+					result = checkProxy(ctx, p.URL(), opts)
+					checkEgress(ctx, result, opts)
+					opts.Cache.Put(result, opts.Targets)
+					if !result.Alive() {
+						fmt.Printf("🚫 Proxy %s: %v\n", p, result.LastError)
+					} else {
+						fmt.Printf("✅ Proxy %s: %v, failed %d/%d%s\n", p, result.Latency.Round(time.Millisecond), result.Failures, result.Attempts, result.egressInfo())
+					}
+				}
+				if !result.Alive() {
 					continue
 				}
-				fmt.Printf("✅ Proxy %s:%s\n", p.IP, p.Port)
 				mu.Lock()
-				workingURLs = append(workingURLs, proxyURL)
+				working = append(working, result)
 				mu.Unlock()
 			}
 		}()
@@ -122,18 +217,26 @@ func checkProxies(proxies []Proxy, testURL string, workers int) ([]*url.URL, err
 
 	// Ожидаем завершения всех воркеров.
 	wg.Wait()
-	return workingURLs, nil
+	if cached > 0 {
+		fmt.Printf("💾 %d proxies taken from check cache\n", cached)
+	}
+	if err := opts.Cache.Save(); err != nil {
+		fmt.Printf("⚠️ Cant save proxy check cache: %v\n", err)
+	}
+	sortChecked(working)
+	return working, nil
 }
 
-func Get(filePath string) ([]*url.URL, error) {
+// Load получает прокси из источника и проверяет их так же, как Get.
+func Load(ctx context.Context, src Source, opts CheckOptions) ([]*Checked, error) {
 
-	proxies, err := readProxies(filePath)
+	proxies, err := src.Fetch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error reading proxies: %v", err)
+		return nil, fmt.Errorf("error reading proxies from %s: %v", src, err)
 	}
 
 	// Проверяем прокси.
-	workingProxies, err := checkProxies(proxies, "api.telegram.org:443", 10)
+	workingProxies, err := checkProxies(ctx, proxies, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error checking proxies: %v", err)
 	}
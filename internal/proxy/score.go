@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gotd/td/telegram/dcs"
+)
+
+// Checked — результат проверки прокси.
+type Checked struct {
+	URL       *url.URL
+	Latency   time.Duration // средняя задержка успешных соединений
+	Attempts  int
+	Failures  int
+	LastError error
+	CheckedAt time.Time
+	EgressIP  string // внешний IP прокси, если проверялся
+	Country   string // ISO код страны внешнего IP
+	// Reached — результат по каждому адресу проверки: true, если
+	// соединение с ним через прокси удалось.
+	Reached map[string]bool
+}
+
+func (c *Checked) egressInfo() string {
+	if c.EgressIP == "" {
+		return ""
+	}
+	if c.Country == "" {
+		return ", egress " + c.EgressIP
+	}
+	return ", egress " + c.EgressIP + " (" + c.Country + ")"
+}
+
+// record учитывает результат одной попытки соединения с target. Пустой
+// target — попытка не относится к конкретному адресу проверки.
+func (c *Checked) record(target string, latency time.Duration, err error) {
+	if target != "" {
+		if c.Reached == nil {
+			c.Reached = map[string]bool{}
+		}
+		c.Reached[target] = err == nil
+	}
+	if err != nil {
+		c.Attempts++
+		c.Failures++
+		c.LastError = err
+		return
+	}
+	ok := c.Attempts - c.Failures
+	c.Latency = (c.Latency*time.Duration(ok) + latency) / time.Duration(ok+1)
+	c.Attempts++
+}
+
+// Alive сообщает, что хотя бы одно соединение через прокси удалось.
+// Доступность конкретного DC проверяет Reaches.
+func (c *Checked) Alive() bool {
+	return c.Attempts > c.Failures
+}
+
+// Reaches сообщает, доступен ли addr через прокси. Если addr не
+// проверялся (в том числе у MTProxy, который проверяется только
+// соединением с сервером), ответ — Alive().
+func (c *Checked) Reaches(addr string) bool {
+	if ok, checked := c.Reached[addr]; checked {
+		return ok
+	}
+	return c.Alive()
+}
+
+// FailureRate — доля неудачных соединений.
+func (c *Checked) FailureRate() float64 {
+	if c.Attempts == 0 {
+		return 1
+	}
+	return float64(c.Failures) / float64(c.Attempts)
+}
+
+// Score — оценка прокси: средняя задержка в миллисекундах со штрафом за
+// неудачные соединения. Чем меньше, тем лучше.
+func (c *Checked) Score() float64 {
+	if !c.Alive() {
+		return math.Inf(1)
+	}
+	ms := float64(c.Latency) / float64(time.Millisecond)
+	return ms * (1 + 4*c.FailureRate())
+}
+
+func sortChecked(list []*Checked) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Score() < list[j].Score()
+	})
+}
+
+// URLs возвращает URL прокси в порядке списка.
+func URLs(list []*Checked) []*url.URL {
+	urls := make([]*url.URL, 0, len(list))
+	for _, c := range list {
+		urls = append(urls, c.URL)
+	}
+	return urls
+}
+
+// DCTargets возвращает адреса для проверки прокси: сначала переданные
+// (например, адреса DC из сессий аккаунтов), затем основные IPv4 адреса
+// production DC Telegram. Дубликаты убираются.
+func DCTargets(extra ...string) []string {
+	seen := map[string]bool{}
+	var targets []string
+	add := func(addr string) {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			targets = append(targets, addr)
+		}
+	}
+
+	for _, addr := range extra {
+		add(addr)
+	}
+	for _, dc := range dcs.Prod().Options {
+		if dc.Ipv6 || dc.MediaOnly || dc.CDN || dc.TCPObfuscatedOnly {
+			continue
+		}
+		add(net.JoinHostPort(dc.IPAddress, strconv.Itoa(dc.Port)))
+	}
+	return targets
+}
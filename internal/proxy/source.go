@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sourceClient — клиент HTTPSource по умолчанию. Таймаут не даёт зависшему
+// API провайдера остановить пополнение пула навсегда.
+var sourceClient = &http.Client{Timeout: 30 * time.Second}
+
+// Source — источник списка прокси: файл, API провайдера и т.п.
+type Source interface {
+	// Fetch возвращает текущий список прокси источника.
+	Fetch(ctx context.Context) ([]Proxy, error)
+	// String возвращает описание источника для логов.
+	String() string
+}
+
+// FileSource читает прокси из локального файла, по одной на строку.
+type FileSource struct {
+	Path string
+}
+
+func (s *FileSource) Fetch(ctx context.Context) ([]Proxy, error) {
+	proxies, invalid, err := readProxies(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	reportInvalid(s.Path, invalid)
+	return proxies, nil
+}
+
+func (s *FileSource) String() string {
+	return s.Path
+}
+
+// HTTPSource получает прокси из HTTP API провайдера. Ответ может быть
+// текстом (по прокси на строку, в любом формате parseProxyLine) или JSON:
+// массивом строк, массивом объектов или объектом с таким массивом в поле
+// "proxies", "data" или "items".
+type HTTPSource struct {
+	URL string
+	// Format — "text" или "json"; если пусто, определяется по Content-Type.
+	Format string
+	// RotateURL, если задан, запрашивается перед каждым получением списка,
+	// чтобы провайдер сменил IP выходных узлов.
+	RotateURL string
+	Header    http.Header
+	// Client — HTTP клиент запросов; по умолчанию клиент с таймаутом 30с.
+	Client *http.Client
+}
+
+func (s *HTTPSource) String() string {
+	return s.URL
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context) ([]Proxy, error) {
+	if s.RotateURL != "" {
+		body, _, err := s.get(ctx, s.RotateURL)
+		if err != nil {
+			return nil, fmt.Errorf("rotate: %v", err)
+		}
+		body.Close()
+	}
+
+	body, contentType, err := s.get(ctx, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	format := s.Format
+	if format == "" {
+		format = "text"
+		if mediaType, _, _ := mime.ParseMediaType(contentType); strings.HasSuffix(mediaType, "json") {
+			format = "json"
+		}
+	}
+
+	var (
+		proxies []Proxy
+		invalid []lineError
+	)
+	switch format {
+	case "json":
+		proxies, invalid, err = decodeJSONProxies(body)
+	case "text":
+		proxies, invalid, err = scanProxies(body)
+	default:
+		return nil, fmt.Errorf("unknown source format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	reportInvalid(s.URL, invalid)
+	return proxies, nil
+}
+
+func (s *HTTPSource) get(ctx context.Context, rawURL string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+
+	client := s.Client
+	if client == nil {
+		client = sourceClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// scanProxies разбирает текстовый список прокси, по одной на строку.
+func scanProxies(r io.Reader) ([]Proxy, []lineError, error) {
+	var (
+		proxies []Proxy
+		invalid []lineError
+		lineNo  int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseProxyLine(line)
+		if err != nil {
+			invalid = append(invalid, lineError{Line: lineNo, Err: err})
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading proxies: %v", err)
+	}
+	return proxies, invalid, nil
+}
+
+// decodeJSONProxies разбирает JSON ответ провайдера.
+func decodeJSONProxies(r io.Reader) ([]Proxy, []lineError, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, nil, fmt.Errorf("expected JSON array or object")
+		}
+		for _, key := range []string{"proxies", "data", "items"} {
+			if v, ok := wrapper[key]; ok {
+				if err := json.Unmarshal(v, &items); err == nil {
+					break
+				}
+			}
+		}
+		if items == nil {
+			return nil, nil, fmt.Errorf("no proxy list in JSON object")
+		}
+	}
+
+	var (
+		proxies []Proxy
+		invalid []lineError
+	)
+	for i, item := range items {
+		p, err := decodeJSONProxy(item)
+		if err != nil {
+			invalid = append(invalid, lineError{Line: i + 1, Err: err})
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, invalid, nil
+}
+
+// decodeJSONProxy разбирает элемент списка: строку или объект с полями
+// scheme/type/protocol, host/ip/server, port, login/username/user, password/pass.
+func decodeJSONProxy(item json.RawMessage) (Proxy, error) {
+	var line string
+	if err := json.Unmarshal(item, &line); err == nil {
+		return parseProxyLine(strings.TrimSpace(line))
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(item, &obj); err != nil {
+		return Proxy{}, fmt.Errorf("expected string or object")
+	}
+	field := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := obj[k].(type) {
+			case string:
+				return v
+			case float64:
+				return strconv.Itoa(int(v))
+			}
+		}
+		return ""
+	}
+
+	p := Proxy{
+		Scheme:   strings.ToLower(field("scheme", "type", "protocol")),
+		Host:     field("host", "ip", "server", "address"),
+		Port:     field("port"),
+		Login:    field("login", "username", "user"),
+		Password: field("password", "pass"),
+	}
+	if p.Scheme == "" {
+		p.Scheme = defaultScheme
+	}
+	if !supportedSchemes[p.Scheme] {
+		return Proxy{}, fmt.Errorf("unsupported scheme %q", p.Scheme)
+	}
+	return p, validateHostPort(p.Host, p.Port)
+}
+
+func reportInvalid(source string, invalid []lineError) {
+	if len(invalid) == 0 {
+		return
+	}
+	fmt.Printf("⚠️ %s: skipped %d invalid lines\n", source, len(invalid))
+	for _, e := range invalid {
+		fmt.Printf("   %v\n", e)
+	}
+}
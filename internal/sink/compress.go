@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression — алгоритм сжатия файлов результатов.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression разбирает название алгоритма сжатия из конфига.
+func ParseCompression(value string) (Compression, error) {
+	switch c := Compression(strings.ToLower(strings.TrimSpace(value))); c {
+	case CompressionNone, "none":
+		return CompressionNone, nil
+	case CompressionGzip, "gz":
+		return CompressionGzip, nil
+	case CompressionZstd, "zst":
+		return CompressionZstd, nil
+	}
+	return CompressionNone, fmt.Errorf("unknown compression %q", value)
+}
+
+// Ext возвращает расширение файла для алгоритма сжатия.
+func (c Compression) Ext() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// flushWriter — поток записи, который можно сбросить на диск, не закрывая.
+type flushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type nopFlushWriter struct {
+	io.Writer
+}
+
+func (nopFlushWriter) Flush() error { return nil }
+func (nopFlushWriter) Close() error { return nil }
+
+// compressWriter оборачивает w сжатием c. Close завершает поток сжатия,
+// но не закрывает w.
+func compressWriter(w io.Writer, c Compression) (flushWriter, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionNone:
+		return nopFlushWriter{w}, nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", c)
+}
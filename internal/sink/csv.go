@@ -2,67 +2,219 @@ package sink
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"reflect"
+	"slices"
 	"strings"
 	"sync"
 )
 
+// utf8BOM — метка порядка байтов, по которой Excel узнаёт UTF-8.
+const utf8BOM = "\ufeff"
+
+// CSVOptions — параметры CSVHandler.
+type CSVOptions struct {
+	// Columns — колонки и их порядок; по умолчанию DefaultColumns.
+	Columns []Column
+	// Delimiter — разделитель полей; по умолчанию запятая.
+	Delimiter rune
+	// BOM добавляет в начало файла UTF-8 BOM, чтобы Excel правильно
+	// показывал кириллицу.
+	BOM bool
+	// Append дописывает в существующий файл вместо перезаписи. Заголовок
+	// файла должен совпадать с колонками.
+	Append bool
+	// Dedupe — "id" или "username": не писать пользователей, которые уже
+	// есть в файле или были записаны раньше в этом запуске.
+	Dedupe string
+}
+
 type CSVHandler struct {
-	mu       sync.Mutex
-	writer   *csv.Writer
-	file     *os.File
-	headers  []string
-	initOnce sync.Once
+	mu      sync.Mutex
+	writer  *csv.Writer
+	file    *os.File
+	columns []Column
+	dedupe  *dedupeSet
 }
 
-func NewCSVHandler(filename string) (*CSVHandler, error) {
+// NewCSVHandler создаёт CSV файл и сразу пишет заголовок по схеме колонок.
+// В режиме Append существующий файл проверяется и дописывается.
+func NewCSVHandler(filename string, opts CSVOptions) (*CSVHandler, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = DefaultColumns
+	}
+	dedupe, err := newDedupeSet(opts.Dedupe)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &CSVHandler{columns: opts.Columns, dedupe: dedupe}
+	if opts.Append {
+		resumed, err := h.resume(filename, opts)
+		if err != nil {
+			return nil, err
+		}
+		if resumed {
+			return h, nil
+		}
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
 		return nil, err
 	}
-	return &CSVHandler{
-		writer: csv.NewWriter(f),
-		file:   f,
-	}, nil
+	h.file = f
+	h.writer = newCSVWriter(f, opts.Delimiter)
+	if err := h.writeHeader(opts.BOM); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return h, nil
 }
 
-func (h *CSVHandler) Handle(result any) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+func newCSVWriter(w io.Writer, delimiter rune) *csv.Writer {
+	writer := csv.NewWriter(w)
+	if delimiter != 0 {
+		writer.Comma = delimiter
+	}
+	return writer
+}
+
+// resume открывает существующий непустой файл на дозапись: проверяет, что
+// заголовок совпадает с колонками, и собирает ключи для дедупликации.
+// Возвращает false, если файла нет или он пуст.
+func (h *CSVHandler) resume(filename string, opts CSVOptions) (bool, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		f.Close()
+		return false, err
+	}
 
-	val := reflect.ValueOf(result)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-	}
-	typ := val.Type()
-
-	h.initOnce.Do(func() {
-		var headers []string
-		for i := 0; i < val.NumField(); i++ {
-			field := typ.Field(i)
-			jsonTag := field.Tag.Get("json")
-			if jsonTag != "" {
-				headers = append(headers, strings.Split(jsonTag, ",")[0])
-			} else {
-				headers = append(headers, field.Name)
+	if err := h.readExisting(f, opts.Delimiter); err != nil {
+		f.Close()
+		return false, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	// последняя строка могла оборваться при падении — начинаем с новой
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		f.Close()
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return false, err
+	}
+	if last[0] != '\n' {
+		if _, err := f.WriteString("\n"); err != nil {
+			f.Close()
+			return false, err
+		}
+	}
+
+	h.file = f
+	h.writer = newCSVWriter(f, opts.Delimiter)
+	return true, nil
+}
+
+// readExisting проверяет заголовок файла и запоминает ключи записанных строк.
+func (h *CSVHandler) readExisting(r io.Reader, delimiter rune) error {
+	reader := csv.NewReader(r)
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], utf8BOM)
+	}
+	want := Headers(h.columns)
+	if !slices.Equal(header, want) {
+		return fmt.Errorf("CSV header %q does not match columns %q", header, want)
+	}
+
+	if h.dedupe == nil {
+		return nil
+	}
+	keyColumn := -1
+	for i, col := range h.columns {
+		if fieldName(col.Field) == fieldName(h.dedupe.field) {
+			keyColumn = i
+			break
+		}
+	}
+	if keyColumn < 0 {
+		return fmt.Errorf("dedupe field %s is not among the columns", h.dedupe.field)
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// оборванная последняя строка не мешает дозаписи
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				continue
 			}
+			return err
+		}
+		if keyColumn < len(row) {
+			h.dedupe.add(row[keyColumn])
+		}
+	}
+}
+
+func (h *CSVHandler) writeHeader(bom bool) error {
+	if bom {
+		if _, err := h.file.WriteString(utf8BOM); err != nil {
+			return err
 		}
-		h.headers = headers
-		_ = h.writer.Write(headers)
-	})
+	}
+	if err := h.writer.Write(Headers(h.columns)); err != nil {
+		return err
+	}
+	h.writer.Flush()
+	return h.writer.Error()
+}
 
-	var row []string
-	for i := 0; i < val.NumField(); i++ {
-		f := val.Field(i)
-		row = append(row, fmt.Sprintf("%v", f.Interface()))
+func (h *CSVHandler) Handle(result any) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dedupe.seenResult(result) {
+		return nil
+	}
+	if err := h.writer.Write(Row(h.columns, result)); err != nil {
+		return err
 	}
-	return h.writer.Write(row)
+	// csv.Writer буферизует строки: ошибка записи в файл (например, нет
+	// места на диске) видна только через Error после сброса буфера
+	return h.writer.Error()
 }
 
 func (h *CSVHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	h.writer.Flush()
+	if err := h.writer.Error(); err != nil {
+		h.file.Close()
+		return err
+	}
 	return h.file.Close()
 }
 
@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// dedupeFields сопоставляет ключ дедупликации с полем записи.
+var dedupeFields = map[string]string{
+	"id":       "ID",
+	"username": "Username",
+}
+
+// dedupeSet помнит ключи уже записанных результатов, чтобы при дозаписи
+// в существующий файл не дублировать пользователей.
+type dedupeSet struct {
+	field string
+	seen  map[string]struct{}
+}
+
+// newDedupeSet создаёт набор для ключа "id" или "username"; для пустого
+// ключа возвращает nil — дедупликация выключена.
+func newDedupeSet(key string) (*dedupeSet, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		return nil, nil
+	}
+	field, ok := dedupeFields[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown dedupe key %q", key)
+	}
+	return &dedupeSet{field: field, seen: map[string]struct{}{}}, nil
+}
+
+// normalize приводит значение ключа к виду для сравнения: username в
+// Telegram не зависит от регистра, нулевой ID означает «нет ключа».
+func (d *dedupeSet) normalize(value string) string {
+	value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "@")
+	if value == "0" {
+		return ""
+	}
+	return value
+}
+
+// add запоминает ключ из существующих данных.
+func (d *dedupeSet) add(value string) {
+	if value = d.normalize(value); value != "" {
+		d.seen[value] = struct{}{}
+	}
+}
+
+// seenResult сообщает, записан ли уже результат с таким ключом, и
+// запоминает ключ нового результата. Записи без ключа не отбрасываются.
+func (d *dedupeSet) seenResult(result any) bool {
+	if d == nil {
+		return false
+	}
+	v, ok := fieldByPath(reflect.ValueOf(result), d.field)
+	if !ok {
+		return false
+	}
+	key := d.normalize(formatText(v))
+	if key == "" {
+		return false
+	}
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = struct{}{}
+	return false
+}
@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// fanoutQueueSize — сколько пачек может ждать отстающий обработчик,
+	// прежде чем Handle и HandleBatch начнут ждать его.
+	fanoutQueueSize = 16
+	// DefaultQueueTimeout — сколько по умолчанию ждать места в очереди
+	// обработчика, если обработчиков несколько.
+	DefaultQueueTimeout = 10 * time.Second
+)
+
+// Route — обработчик в составе FanoutHandler.
+type Route struct {
+	// Name — имя обработчика для логов.
+	Name    string
+	Handler ResultHandler
+	// Filter отбирает записи для обработчика; nil — все записи.
+	Filter Filter
+	// QueueTimeout — сколько ждать места в заполненной очереди обработчика.
+	// После этого пачка для него выбрасывается и учитывается как ошибка, а
+	// следующие пачки выбрасываются сразу, пока очередь не освободится, —
+	// так отказавший обработчик не останавливает остальные. 0 —
+	// DefaultQueueTimeout, отрицательное значение — ждать без ограничения.
+	// С единственным обработчиком очередь ждёт всегда: переполнение решает
+	// политика OnFull у ResultSink.
+	QueueTimeout time.Duration
+}
+
+type route struct {
+	Route
+	ch       chan []any
+	done     chan struct{}
+	failures atomic.Int64
+	dropped  atomic.Int64
+	// stalled — последняя пачка не дождалась места в очереди.
+	stalled bool
+	errs    *fanoutErrors
+}
+
+// fanoutErrors копит ошибки обработчиков, пока их не заберёт Handle или Flush.
+type fanoutErrors struct {
+	mu      sync.Mutex
+	pending []error
+}
+
+func (e *fanoutErrors) add(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, err)
+}
+
+func (e *fanoutErrors) take() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := errors.Join(e.pending...)
+	e.pending = nil
+	return err
+}
+
+// FanoutHandler рассылает каждый результат нескольким обработчикам.
+// Каждый обработчик работает в своей горутине со своей очередью, поэтому
+// короткие задержки одного обработчика не задерживают остальные. Когда
+// очередь отстающего обработчика заполнена, FanoutHandler ждёт его до
+// QueueTimeout — так короткое переполнение доходит до ResultSink и решается
+// его политикой OnFull, а обработчик, который завис надолго, теряет свои
+// пачки вместо того, чтобы остановить остальные. Пачки из ResultSink
+// передаются обработчикам целиком. Ошибки обработчиков возвращаются из
+// следующего вызова Handle или из Flush.
+type FanoutHandler struct {
+	routes []*route
+	errs   fanoutErrors
+}
+
+func NewFanoutHandler(routes ...Route) *FanoutHandler {
+	h := &FanoutHandler{}
+	for _, r := range routes {
+		switch {
+		case len(routes) == 1:
+			r.QueueTimeout = -1
+		case r.QueueTimeout == 0:
+			r.QueueTimeout = DefaultQueueTimeout
+		}
+		rt := &route{
+			Route: r,
+			ch:    make(chan []any, fanoutQueueSize),
+			done:  make(chan struct{}),
+			errs:  &h.errs,
+		}
+		h.routes = append(h.routes, rt)
+		go rt.run()
+	}
+	return h
+}
+
+func (r *route) run() {
+	defer close(r.done)
+	for batch := range r.ch {
+		r.handle(batch)
+	}
+}
+
+// handle передаёт пачку обработчику, превращая панику в ошибку.
+func (r *route) handle(batch []any) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.report(fmt.Errorf("panic: %v", p))
+		}
+	}()
+	handleBatch(r.Handler, batch, r.report)
+}
+
+func (r *route) report(err error) {
+	r.failures.Add(int64(countErrors(err)))
+	r.errs.add(fmt.Errorf("sink %s: %w", r.Name, err))
+}
+
+// send ставит пачку в очередь обработчика, ожидая места не дольше
+// QueueTimeout. Пока обработчик не разгрузил очередь после выброшенной
+// пачки, следующие выбрасываются без ожидания. Вызывается из HandleBatch,
+// который не вызывается конкурентно.
+func (r *route) send(batch []any) {
+	if r.QueueTimeout < 0 {
+		r.ch <- batch
+		return
+	}
+	select {
+	case r.ch <- batch:
+		r.stalled = false
+		return
+	default:
+	}
+	if !r.stalled {
+		timer := time.NewTimer(r.QueueTimeout)
+		defer timer.Stop()
+		select {
+		case r.ch <- batch:
+			return
+		case <-timer.C:
+			r.stalled = true
+			log.Printf("⚠️ sink %s: queue is full for %v, dropping results until it drains", r.Name, r.QueueTimeout)
+		}
+	}
+	r.dropped.Add(int64(len(batch)))
+	r.failures.Add(int64(len(batch)))
+	r.errs.add(fmt.Errorf("sink %s: queue is full, %d results dropped", r.Name, len(batch)))
+}
+
+func (h *FanoutHandler) Handle(result any) error {
+	return h.HandleBatch([]any{result})
+}
+
+// HandleBatch отдаёт каждому обработчику подходящие под его фильтр записи
+// одной пачкой, поэтому BatchHandler получает их так, как их собрал
+// ResultSink.
+func (h *FanoutHandler) HandleBatch(results []any) error {
+	for _, r := range h.routes {
+		batch := results
+		if r.Filter != nil {
+			batch = make([]any, 0, len(results))
+			for _, result := range results {
+				if r.Filter(result) {
+					batch = append(batch, result)
+				}
+			}
+		}
+		if len(batch) > 0 {
+			r.send(batch)
+		}
+	}
+	return h.errs.take()
+}
+
+// Flush дожидается обработки очередей и финализирует все обработчики.
+// Возвращает ещё не полученные ошибки записи и ошибки финализации.
+func (h *FanoutHandler) Flush() error {
+	for _, r := range h.routes {
+		close(r.ch)
+	}
+	var errs []error
+	for _, r := range h.routes {
+		<-r.done
+		if err := r.Handler.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", r.Name, err))
+		}
+		if failures := r.failures.Load(); failures > 0 {
+			log.Printf("⚠️ sink %s: %d failed, %d of them dropped on full queue", r.Name, failures, r.dropped.Load())
+		}
+	}
+	return errors.Join(append([]error{h.errs.take()}, errs...)...)
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingHandler считает записи; если block не nil, Handle ждёт его закрытия.
+type countingHandler struct {
+	mu      sync.Mutex
+	block   chan struct{}
+	handled int
+}
+
+func (h *countingHandler) Handle(result any) error {
+	if h.block != nil {
+		<-h.block
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handled++
+	return nil
+}
+
+func (h *countingHandler) Flush() error {
+	return nil
+}
+
+func (h *countingHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.handled
+}
+
+func TestFanoutStalledRouteDoesNotBlockOthers(t *testing.T) {
+	stuck := &countingHandler{block: make(chan struct{})}
+	fast := &countingHandler{}
+	h := NewFanoutHandler(
+		Route{Name: "stuck", Handler: stuck, QueueTimeout: 10 * time.Millisecond},
+		Route{Name: "fast", Handler: fast, QueueTimeout: 10 * time.Millisecond},
+	)
+
+	const total = fanoutQueueSize * 4
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < total; i++ {
+			if e := h.Handle(i); e != nil {
+				err = e
+			}
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("no error reported for results dropped on a full queue")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fanout is blocked by a stalled route")
+	}
+
+	close(stuck.block)
+	if err := h.Flush(); err != nil {
+		t.Logf("Flush: %v", err)
+	}
+	if got := fast.count(); got != total {
+		t.Errorf("fast route handled %d results, want %d", got, total)
+	}
+	if got := stuck.count(); got >= total {
+		t.Errorf("stuck route handled %d results, want some dropped", got)
+	}
+	if dropped := h.routes[0].dropped.Load(); dropped+int64(stuck.count()) != total {
+		t.Errorf("stuck route: %d handled + %d dropped, want %d", stuck.count(), dropped, total)
+	}
+}
+
+func TestFanoutSingleRouteBlocks(t *testing.T) {
+	stuck := &countingHandler{block: make(chan struct{})}
+	h := NewFanoutHandler(Route{Name: "stuck", Handler: stuck, QueueTimeout: time.Millisecond})
+
+	const total = fanoutQueueSize + 2
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < total; i++ {
+			h.Handle(i)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("single route dropped results instead of waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(stuck.block)
+	<-done
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := stuck.count(); got != total {
+		t.Errorf("handled %d results, want %d", got, total)
+	}
+}
@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+	"tg-online-checker/internal/model"
+	"time"
+)
+
+// Filter решает, передавать ли результат обработчику.
+type Filter func(result any) bool
+
+// ParseFilter разбирает выражение фильтра — условия через "&", которые
+// должны выполняться одновременно:
+//
+//	users      — только найденные пользователи (model.User)
+//	errors     — только ошибки (model.CheckError)
+//	all        — все записи
+//	premium    — пользователи с Premium, "!premium" — без него
+//	online<24h — пользователи, которые в сети сейчас или были в сети за
+//	             указанный период; со скрытым временем визита не проходят
+//
+// Пустое выражение равно "users": ошибки попадают только в обработчики,
+// которые запросили их явно.
+func ParseFilter(expr string) (Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		expr = "users"
+	}
+
+	var filters []Filter
+	for _, term := range strings.Split(expr, "&") {
+		f, err := parseFilterTerm(strings.TrimSpace(term))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return func(result any) bool {
+		for _, f := range filters {
+			if !f(result) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseFilterTerm(term string) (Filter, error) {
+	switch strings.ToLower(term) {
+	case "all", "*":
+		return func(any) bool { return true }, nil
+	case "users":
+		return func(result any) bool {
+			_, ok := result.(*model.User)
+			return ok
+		}, nil
+	case "errors":
+		return func(result any) bool {
+			_, ok := result.(*model.CheckError)
+			return ok
+		}, nil
+	case "premium":
+		return userFilter(func(u *model.User) bool { return u.Premium }), nil
+	case "!premium":
+		return userFilter(func(u *model.User) bool { return !u.Premium }), nil
+	}
+
+	if window, ok := strings.CutPrefix(strings.ToLower(term), "online<"); ok {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %v", term, err)
+		}
+		return userFilter(func(u *model.User) bool {
+			if u.Status == model.StatusOnline {
+				return true
+			}
+			// WasOnline == 0 — точное время скрыто или неизвестно
+			if u.WasOnline == 0 {
+				return false
+			}
+			return time.Since(time.Unix(int64(u.WasOnline), 0)) <= d
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown filter %q", term)
+}
+
+// userFilter применяет условие к model.User; остальные записи отбрасываются.
+func userFilter(match func(u *model.User) bool) Filter {
+	return func(result any) bool {
+		u, ok := result.(*model.User)
+		return ok && match(u)
+	}
+}
@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"testing"
+	"tg-online-checker/internal/model"
+	"time"
+)
+
+func TestFilterOnline(t *testing.T) {
+	filter, err := ParseFilter("online<24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		result any
+		want   bool
+	}{
+		{"online now", &model.User{Status: model.StatusOnline}, true},
+		{"offline within window", &model.User{Status: model.StatusOffline, WasOnline: int(time.Now().Add(-time.Hour).Unix())}, true},
+		{"offline before window", &model.User{Status: model.StatusOffline, WasOnline: int(time.Now().Add(-48 * time.Hour).Unix())}, false},
+		{"recently", &model.User{Status: model.StatusRecently}, false},
+		{"hidden", &model.User{Status: model.StatusHidden}, false},
+		{"error", &model.CheckError{Username: "missing"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter(tt.result); got != tt.want {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterCombined(t *testing.T) {
+	filter, err := ParseFilter("premium & online<1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter(&model.User{Premium: true, Status: model.StatusOnline}) {
+		t.Error("online premium user filtered out")
+	}
+	if filter(&model.User{Status: model.StatusOnline}) {
+		t.Error("online user without premium passed")
+	}
+	if _, err := ParseFilter("online<soon"); err == nil {
+		t.Error("invalid window accepted")
+	}
+}
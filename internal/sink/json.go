@@ -1,39 +1,145 @@
 package sink
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"sync"
 )
 
+// JSONOptions — параметры JSONHandler.
+type JSONOptions struct {
+	// Append сохраняет записи из существующего файла: новые результаты
+	// добавляются в конец того же массива.
+	Append bool
+	// Dedupe — "id" или "username": не добавлять пользователей, которые
+	// уже есть в файле или были записаны раньше в этом запуске.
+	Dedupe string
+}
+
 type JSONHandler struct {
-	mu      sync.Mutex
-	file    *os.File
-	results []any
+	mu       sync.Mutex
+	filename string
+	existing []json.RawMessage
+	results  []any
+	dedupe   *dedupeSet
 }
 
-func NewJSONHandler(filename string) (*JSONHandler, error) {
-	f, err := os.Create(filename)
+func NewJSONHandler(filename string, opts JSONOptions) (*JSONHandler, error) {
+	dedupe, err := newDedupeSet(opts.Dedupe)
+	if err != nil {
+		return nil, err
+	}
+	h := &JSONHandler{filename: filename, dedupe: dedupe}
+
+	if opts.Append {
+		f, err := os.Open(filename)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			err = h.readExisting(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+		}
+	}
+
+	// файл перезаписывается только в Flush, но ошибку доступа лучше
+	// увидеть сразу, до начала запуска
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, err
 	}
-	return &JSONHandler{file: f}, nil
+	f.Close()
+	return h, nil
+}
+
+// readExisting читает массив из существующего файла и запоминает ключи
+// записей для дедупликации. Пустой файл — пустой массив.
+func (h *JSONHandler) readExisting(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	if err := json.NewDecoder(f).Decode(&h.existing); err != nil {
+		return fmt.Errorf("existing file is not a JSON array: %w", err)
+	}
+	if h.dedupe == nil {
+		return nil
+	}
+	for _, raw := range h.existing {
+		var record map[string]any
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&record); err != nil {
+			continue
+		}
+		for key, value := range record {
+			if fieldName(key) == fieldName(h.dedupe.field) {
+				h.dedupe.add(jsonKey(value))
+			}
+		}
+	}
+	return nil
+}
+
+// jsonKey приводит значение ключа из JSON к тексту.
+func jsonKey(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
 }
 
 func (h *JSONHandler) Handle(result any) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if h.dedupe.seenResult(result) {
+		return nil
+	}
 	h.results = append(h.results, result)
 	return nil
 }
 
+// Flush записывает все результаты во временный файл и заменяет им
+// исходный: при падении или нехватке места старые данные остаются целыми.
 func (h *JSONHandler) Flush() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	enc := json.NewEncoder(h.file)
+
+	all := make([]any, 0, len(h.existing)+len(h.results))
+	for _, raw := range h.existing {
+		all = append(all, raw)
+	}
+	all = append(all, h.results...)
+
+	tmp := h.filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
 	enc.SetIndent("", "  ") // красиво форматируем
-	if err := enc.Encode(h.results); err != nil {
+	if err := enc.Encode(all); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
 		return err
 	}
-	return h.file.Close()
+	return os.Rename(tmp, h.filename)
 }
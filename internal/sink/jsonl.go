@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// JSONLHandler пишет результаты в формате JSON Lines — по объекту на
+// строку. В отличие от JSONHandler, каждая запись сразу сбрасывается на
+// диск, поэтому при падении теряется не больше одной записи, а память не
+// растёт с числом результатов.
+type JSONLHandler struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	zw   flushWriter
+	enc  *json.Encoder
+}
+
+func NewJSONLHandler(filename string, compression Compression) (*JSONLHandler, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(f)
+	zw, err := compressWriter(buf, compression)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &JSONLHandler{
+		file: f,
+		buf:  buf,
+		zw:   zw,
+		enc:  json.NewEncoder(zw),
+	}, nil
+}
+
+func (h *JSONLHandler) Handle(result any) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.enc.Encode(result); err != nil {
+		return err
+	}
+	if err := h.zw.Flush(); err != nil {
+		return err
+	}
+	return h.buf.Flush()
+}
+
+func (h *JSONLHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.zw.Close(); err != nil {
+		h.file.Close()
+		return err
+	}
+	if err := h.buf.Flush(); err != nil {
+		h.file.Close()
+		return err
+	}
+	return h.file.Close()
+}
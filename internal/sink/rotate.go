@@ -0,0 +1,206 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions — когда начинать новый файл. Нулевые значения отключают
+// соответствующее условие.
+type RotateOptions struct {
+	// MaxRecords — сколько записей пишется в один файл.
+	MaxRecords int
+	// MaxBytes — размер файла, после которого начинается новый. Проверяется
+	// по размеру на диске, поэтому срабатывает с запаздыванием на буфер
+	// обработчика (у CSV — несколько КБ) и не подходит обработчикам, которые
+	// пишут файл только в Flush (JSONHandler, XLSXHandler).
+	MaxBytes int64
+	// Interval — сколько времени пишется один файл.
+	Interval time.Duration
+	// Compression — чем сжимать закрытые файлы; исходный файл удаляется.
+	Compression Compression
+}
+
+// Enabled сообщает, задано ли хоть одно условие ротации.
+func (o RotateOptions) Enabled() bool {
+	return o.MaxRecords > 0 || o.MaxBytes > 0 || o.Interval > 0
+}
+
+// RotatingHandler пишет результаты в последовательность файлов с меткой
+// времени в имени ("results-20060102-150405.csv"), начиная новый файл по
+// числу записей, размеру или времени. Каждый файл пишет свой обработчик,
+// созданный open; закрытые файлы при необходимости сжимаются в фоне.
+type RotatingHandler struct {
+	mu       sync.Mutex
+	base     string
+	opts     RotateOptions
+	open     func(filename string) (ResultHandler, error)
+	current  ResultHandler
+	path     string
+	records  int
+	openedAt time.Time
+	compress sync.WaitGroup
+	errs     []error
+}
+
+func NewRotatingHandler(filename string, opts RotateOptions, open func(filename string) (ResultHandler, error)) (*RotatingHandler, error) {
+	h := &RotatingHandler{base: filename, opts: opts, open: open}
+	if err := h.openSegment(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// segmentPath возвращает имя нового файла: base с меткой времени перед
+// расширением и номером, если такой файл уже есть.
+func (h *RotatingHandler) segmentPath(now time.Time) string {
+	ext := filepath.Ext(h.base)
+	stem := strings.TrimSuffix(h.base, ext)
+	name := stem + "-" + now.Format("20060102-150405")
+	path := name + ext
+	for i := 1; fileExists(path) || fileExists(path+h.opts.Compression.Ext()); i++ {
+		path = fmt.Sprintf("%s-%d%s", name, i, ext)
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// openSegment начинает новый файл. Вызывается под h.mu.
+func (h *RotatingHandler) openSegment() error {
+	now := time.Now()
+	path := h.segmentPath(now)
+	handler, err := h.open(path)
+	if err != nil {
+		return err
+	}
+	h.current = handler
+	h.path = path
+	h.records = 0
+	h.openedAt = now
+	return nil
+}
+
+// closeSegment финализирует текущий файл и отдаёт его на сжатие. Ошибки
+// сжатия копятся в h.errs и возвращаются из следующего Handle или Flush.
+// Вызывается под h.mu.
+func (h *RotatingHandler) closeSegment() error {
+	if h.current == nil {
+		return nil
+	}
+	err := h.current.Flush()
+	h.current = nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", h.path, err)
+	}
+	if h.opts.Compression != CompressionNone {
+		path := h.path
+		h.compress.Add(1)
+		go func() {
+			defer h.compress.Done()
+			if err := compressFile(path, h.opts.Compression); err != nil {
+				log.Printf("⚠️ cant compress %s: %v", path, err)
+				h.mu.Lock()
+				h.errs = append(h.errs, err)
+				h.mu.Unlock()
+			}
+		}()
+	}
+	return nil
+}
+
+// due сообщает, пора ли начать новый файл. Вызывается под h.mu.
+func (h *RotatingHandler) due() bool {
+	if h.opts.MaxRecords > 0 && h.records >= h.opts.MaxRecords {
+		return true
+	}
+	if h.opts.Interval > 0 && time.Since(h.openedAt) >= h.opts.Interval {
+		return true
+	}
+	if h.opts.MaxBytes > 0 {
+		if info, err := os.Stat(h.path); err == nil && info.Size() >= h.opts.MaxBytes {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *RotatingHandler) Handle(result any) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// ошибки закрытия и сжатия прошлых файлов возвращаются вместе с
+	// результатом записи, чтобы ResultSink учёл их наравне с ошибками записи
+	closeErr := errors.Join(h.errs...)
+	h.errs = nil
+	if h.current == nil || h.due() {
+		closeErr = errors.Join(closeErr, h.closeSegment())
+		if err := h.openSegment(); err != nil {
+			return errors.Join(closeErr, err)
+		}
+	}
+	if err := h.current.Handle(result); err != nil {
+		return errors.Join(closeErr, err)
+	}
+	h.records++
+	return closeErr
+}
+
+// Flush закрывает текущий файл и дожидается сжатия закрытых файлов.
+func (h *RotatingHandler) Flush() error {
+	h.mu.Lock()
+	err := h.closeSegment()
+	h.mu.Unlock()
+
+	h.compress.Wait()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return errors.Join(append([]error{err}, h.errs...)...)
+}
+
+// compressFile сжимает файл в path+ext и удаляет исходный.
+func compressFile(path string, c Compression) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	target := path + c.Ext()
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	zw, err := compressWriter(dst, c)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,37 @@
+package sink
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// failingFlushHandler принимает записи, но не может финализировать файл.
+type failingFlushHandler struct{}
+
+func (failingFlushHandler) Handle(result any) error { return nil }
+func (failingFlushHandler) Flush() error            { return errors.New("disk full") }
+
+func TestRotatingHandlerReportsCloseError(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "results.csv")
+	h, err := NewRotatingHandler(base, RotateOptions{MaxRecords: 1}, func(string) (ResultHandler, error) {
+		return failingFlushHandler{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(1); err != nil {
+		t.Fatalf("first Handle: %v", err)
+	}
+	err = h.Handle(2)
+	if err == nil {
+		t.Fatal("rotation hid the error of closing the previous file")
+	}
+	if h.current == nil || h.records != 1 {
+		t.Errorf("record was not written to the new file: %d records", h.records)
+	}
+	if err := h.Flush(); err == nil {
+		t.Error("Flush hid the error of closing the last file")
+	}
+}
@@ -0,0 +1,222 @@
+package sink
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formatter превращает значение поля в текст ячейки.
+type Formatter func(v reflect.Value) string
+
+// Formatters — именованные форматтеры для описания колонок в конфиге.
+var Formatters = map[string]Formatter{
+	"text": formatText,
+	"time": formatTime,
+	"bool": formatBool,
+}
+
+// Column — колонка табличного вывода: заголовок, путь к полю записи и
+// форматтер значения.
+type Column struct {
+	Header string
+	// Field — имя поля записи; вложенные поля через точку ("Status.Kind").
+	// Регистр и подчёркивания не учитываются: "was_online" == "WasOnline".
+	Field  string
+	Format Formatter
+	// Kind — имя форматтера из Formatters ("time", "bool", "text"); по нему
+	// форматы с типами ячеек (XLSX) выбирают тип значения.
+	Kind string
+}
+
+// DefaultColumns — колонки по умолчанию для model.User и model.CheckError.
+// Время в них выводится в RFC3339.
+var DefaultColumns = []Column{
+	{Header: "ID", Field: "ID"},
+	{Header: "Username", Field: "Username"},
+	{Header: "Phone", Field: "Phone"},
+	{Header: "FirstName", Field: "FirstName"},
+	{Header: "LastName", Field: "LastName"},
+	{Header: "Premium", Field: "Premium"},
+	{Header: "WasOnline", Field: "WasOnline", Format: formatTime, Kind: "time"},
+	{Header: "AccountID", Field: "AccountID"},
+	{Header: "CheckedAt", Field: "CheckedAt", Format: formatTime, Kind: "time"},
+	{Header: "Error", Field: "Error"},
+}
+
+// ParseColumns разбирает список колонок вида "field[:format][=header]",
+// например "ID", "was_online:time=Last seen", "premium:bool".
+func ParseColumns(specs []string) ([]Column, error) {
+	var columns []Column
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		field, header, hasHeader := strings.Cut(spec, "=")
+		field, format, hasFormat := strings.Cut(strings.TrimSpace(field), ":")
+		col := Column{Field: strings.TrimSpace(field), Header: strings.TrimSpace(header)}
+		if !hasHeader || col.Header == "" {
+			col.Header = col.Field
+		}
+		if hasFormat {
+			kind := strings.ToLower(strings.TrimSpace(format))
+			f, ok := Formatters[kind]
+			if !ok {
+				return nil, fmt.Errorf("column %q: unknown format %q", spec, format)
+			}
+			col.Format = f
+			col.Kind = kind
+		}
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+	return columns, nil
+}
+
+// Headers возвращает заголовки колонок.
+func Headers(columns []Column) []string {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	return headers
+}
+
+// Row возвращает ячейки записи по колонкам. Поля, которых у записи нет,
+// остаются пустыми — так записи разных типов ложатся в одну таблицу.
+func Row(columns []Column, result any) []string {
+	val := reflect.ValueOf(result)
+	row := make([]string, len(columns))
+	for i, col := range columns {
+		v, ok := fieldByPath(val, col.Field)
+		if !ok {
+			continue
+		}
+		format := col.Format
+		if format == nil {
+			format = formatText
+		}
+		row[i] = format(v)
+	}
+	return row
+}
+
+// fieldIndexes кеширует индексы полей по типу и имени.
+var fieldIndexes sync.Map // map[fieldKey][]int
+
+type fieldKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// fieldByPath ищет поле по пути через точку, разыменовывая указатели.
+func fieldByPath(val reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				return reflect.Value{}, false
+			}
+			val = val.Elem()
+		}
+		if val.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		index, ok := fieldIndex(val.Type(), name)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		val = val.FieldByIndex(index)
+	}
+	return val, true
+}
+
+func fieldIndex(typ reflect.Type, name string) ([]int, bool) {
+	key := fieldKey{typ: typ, name: name}
+	if index, ok := fieldIndexes.Load(key); ok {
+		return index.([]int), index.([]int) != nil
+	}
+
+	var found []int
+	want := fieldName(name)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if fieldName(field.Name) == want || (tag != "" && fieldName(tag) == want) {
+			found = field.Index
+			break
+		}
+	}
+	fieldIndexes.Store(key, found)
+	return found, found != nil
+}
+
+// fieldName приводит имя поля к виду для сравнения: без регистра и "_".
+func fieldName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "")
+}
+
+func formatText(v reflect.Value) string {
+	if t, ok := v.Interface().(time.Time); ok {
+		return formatTimeValue(t)
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// formatTime выводит Unix время или time.Time в RFC3339; 0 — пустая ячейка.
+func formatTime(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 {
+			return ""
+		}
+		return formatTimeValue(time.Unix(v.Int(), 0))
+	}
+	return formatText(v)
+}
+
+func formatTimeValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// cellTime приводит Unix время или time.Time к дате в UTC; 0 — пустая ячейка.
+func cellTime(v reflect.Value) (time.Time, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 {
+			return time.Time{}, false
+		}
+		return time.Unix(v.Int(), 0).UTC(), true
+	}
+	if t, ok := v.Interface().(time.Time); ok && !t.IsZero() {
+		return t.UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// formatBool выводит булевы значения как 1 и 0.
+func formatBool(v reflect.Value) string {
+	if v.Kind() == reflect.Bool {
+		if v.Bool() {
+			return "1"
+		}
+		return "0"
+	}
+	return formatText(v)
+}
@@ -1,47 +1,320 @@
 package sink
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// ErrTooManyErrors возвращается из Close, если запись была прервана после
+// MaxErrors ошибок обработчика.
+var ErrTooManyErrors = errors.New("too many result write errors")
+
 type ResultHandler interface {
 	Handle(result any) error
 	Flush() error // если нужно финализировать запись (например, закрыть файл)
 }
 
+// BatchHandler — обработчик, которому выгоднее получать результаты пачкой
+// (одна транзакция, один запрос). ResultSink передаёт ему всё, что
+// накопилось в буфере, но не больше Options.BatchSize за раз.
+type BatchHandler interface {
+	ResultHandler
+	HandleBatch(results []any) error
+}
+
+// FullPolicy — что делать при Submit, если буфер ResultSink заполнен.
+type FullPolicy string
+
+const (
+	// FullBlock — ждать, пока обработчик освободит место.
+	FullBlock FullPolicy = "block"
+	// FullDropOldest — выбросить самый старый результат из буфера.
+	FullDropOldest FullPolicy = "drop-oldest"
+	// FullSpill — сохранить результат во временный файл на диске; он будет
+	// записан, когда буфер опустеет. Порядок результатов при этом не сохраняется.
+	FullSpill FullPolicy = "spill"
+)
+
+// ParseFullPolicy разбирает политику переполнения буфера из конфига.
+func ParseFullPolicy(value string) (FullPolicy, error) {
+	switch policy := FullPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return FullBlock, nil
+	case FullBlock, FullDropOldest, FullSpill:
+		return policy, nil
+	}
+	return FullBlock, fmt.Errorf("unknown buffer policy %q", value)
+}
+
+// Options — параметры ResultSink.
+type Options struct {
+	// OnError вызывается для каждой ошибки обработчика из горутины записи.
+	OnError func(err error)
+	// MaxErrors — после скольких ошибок прекратить запись и закрыть
+	// канал Aborted. 0 — не прерывать.
+	MaxErrors int
+	// BufferSize — сколько результатов может ждать записи, не блокируя
+	// Submit. 0 — без буфера, каждый Submit ждёт обработчика.
+	BufferSize int
+	// BatchSize — сколько результатов передаётся BatchHandler за раз.
+	BatchSize int
+	// OnFull — политика при заполненном буфере; по умолчанию FullBlock.
+	OnFull FullPolicy
+	// SpillPath — временный файл для политики FullSpill.
+	SpillPath string
+}
+
+func (o *Options) setDefaults() {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.OnFull == "" || o.BufferSize <= 0 {
+		// без буфера выбрасывать и выгружать нечего
+		o.OnFull = FullBlock
+	}
+}
+
 type ResultSink struct {
-	ch      chan any
-	handler ResultHandler
-	wg      *sync.WaitGroup
+	ch       chan any
+	handler  ResultHandler
+	wg       *sync.WaitGroup
+	opts     Options
+	spill    *spillQueue
+	failures atomic.Int64
+	dropped  atomic.Int64
+	spilled  atomic.Int64
+	firstErr error
+	aborted  chan struct{}
 }
 
-func NewResultSink(handler ResultHandler) *ResultSink {
+func NewResultSink(handler ResultHandler, opts Options) (*ResultSink, error) {
+	opts.setDefaults()
 	var wg sync.WaitGroup
 	sink := &ResultSink{
-		ch:      make(chan any),
+		ch:      make(chan any, opts.BufferSize),
 		handler: handler,
 		wg:      &wg,
+		opts:    opts,
+		aborted: make(chan struct{}),
+	}
+	if opts.OnFull == FullSpill {
+		if opts.SpillPath == "" {
+			return nil, errors.New("spill path is required for spill policy")
+		}
+		spill, err := openSpillQueue(opts.SpillPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spill file: %w", err)
+		}
+		sink.spill = spill
 	}
 	sink.run()
-	return sink
+	return sink, nil
 }
 
 func (rs *ResultSink) run() {
 	rs.wg.Add(1)
 	go func() {
 		defer rs.wg.Done()
-		for r := range rs.ch {
-			_ = rs.handler.Handle(r) // можно логировать ошибки
+		for {
+			batch, open := rs.next()
+			rs.deliver(batch)
+			if !open {
+				break
+			}
+		}
+		// канал закрыт: дописываем то, что осталось на диске
+		for {
+			batch := rs.popSpill()
+			if len(batch) == 0 {
+				return
+			}
+			rs.deliver(batch)
 		}
 	}()
 }
 
+// next ждёт следующую пачку результатов: всё, что уже есть в буфере, но
+// не больше BatchSize. Если буфер пуст, сначала берутся результаты,
+// выгруженные на диск. open == false — канал закрыт.
+func (rs *ResultSink) next() (batch []any, open bool) {
+	select {
+	case r, ok := <-rs.ch:
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, r)
+	default:
+		if spilled := rs.popSpill(); len(spilled) > 0 {
+			return spilled, true
+		}
+		r, ok := <-rs.ch
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, r)
+	}
+
+	for len(batch) < rs.opts.BatchSize {
+		select {
+		case r, ok := <-rs.ch:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, r)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+func (rs *ResultSink) popSpill() []any {
+	if rs.spill == nil {
+		return nil
+	}
+	batch, err := rs.spill.pop(rs.opts.BatchSize)
+	if err != nil {
+		rs.report(fmt.Errorf("spill: %w", err))
+	}
+	return batch
+}
+
+// deliver передаёт пачку обработчику.
+func (rs *ResultSink) deliver(batch []any) {
+	// после прерывания записи только разгружаем канал, чтобы Submit не блокировался
+	if len(batch) == 0 || rs.isAborted() {
+		return
+	}
+	handleBatch(rs.handler, batch, rs.report)
+}
+
+// handleBatch передаёт пачку обработчику: целиком, если он реализует
+// BatchHandler, иначе по одному результату.
+func handleBatch(handler ResultHandler, batch []any, report func(err error)) {
+	if bh, ok := handler.(BatchHandler); ok && len(batch) > 1 {
+		if err := bh.HandleBatch(batch); err != nil {
+			report(err)
+		}
+		return
+	}
+	for _, r := range batch {
+		if err := handler.Handle(r); err != nil {
+			report(err)
+		}
+	}
+}
+
+// report учитывает ошибку обработчика. Вызывается из горутины записи.
+func (rs *ResultSink) report(err error) {
+	if rs.firstErr == nil {
+		rs.firstErr = err
+	}
+	failures := rs.failures.Add(int64(countErrors(err)))
+	if rs.opts.OnError != nil {
+		rs.opts.OnError(err)
+	}
+	if rs.opts.MaxErrors > 0 && failures >= int64(rs.opts.MaxErrors) && !rs.isAborted() {
+		close(rs.aborted)
+	}
+}
+
+// countErrors возвращает число ошибок, объединённых в err через errors.Join.
+func countErrors(err error) int {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return len(joined.Unwrap())
+	}
+	return 1
+}
+
+func (rs *ResultSink) isAborted() bool {
+	select {
+	case <-rs.aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Aborted закрывается, когда число ошибок записи достигло MaxErrors.
+func (rs *ResultSink) Aborted() <-chan struct{} {
+	return rs.aborted
+}
+
+// Failures возвращает число ошибок записи с начала работы.
+func (rs *ResultSink) Failures() int64 {
+	return rs.failures.Load()
+}
+
+// Submit ставит результат в очередь на запись. При заполненном буфере
+// поведение определяет Options.OnFull.
 func (rs *ResultSink) Submit(result any) {
+	switch rs.opts.OnFull {
+	case FullDropOldest:
+		for {
+			select {
+			case rs.ch <- result:
+				return
+			default:
+			}
+			select {
+			case <-rs.ch:
+				rs.dropped.Add(1)
+			default:
+			}
+		}
+	case FullSpill:
+		select {
+		case rs.ch <- result:
+			return
+		default:
+		}
+		if canSpill(result) {
+			if err := rs.spill.push(result); err == nil {
+				rs.spilled.Add(1)
+				return
+			}
+		}
+	}
 	rs.ch <- result
 }
 
-func (rs *ResultSink) Close() {
+// Dropped возвращает число результатов, выброшенных из-за переполнения буфера.
+func (rs *ResultSink) Dropped() int64 {
+	return rs.dropped.Load()
+}
+
+// Spilled возвращает число результатов, выгруженных на диск.
+func (rs *ResultSink) Spilled() int64 {
+	return rs.spilled.Load()
+}
+
+// Close дожидается записи всех результатов и финализирует обработчик.
+// Возвращает ошибку финализации, а если были ошибки записи — первую из
+// них с общим числом.
+func (rs *ResultSink) Close() error {
 	close(rs.ch)
 	rs.wg.Wait()
-	_ = rs.handler.Flush()
+
+	var errs []error
+	if rs.isAborted() {
+		errs = append(errs, ErrTooManyErrors)
+	}
+	if failures := rs.failures.Load(); failures > 0 {
+		errs = append(errs, fmt.Errorf("%d write errors, first: %w", failures, rs.firstErr))
+	}
+	if dropped := rs.dropped.Load(); dropped > 0 {
+		errs = append(errs, fmt.Errorf("%d results dropped: buffer is full", dropped))
+	}
+	if rs.spill != nil {
+		if err := rs.spill.close(); err != nil {
+			errs = append(errs, fmt.Errorf("spill: %w", err))
+		}
+	}
+	if err := rs.handler.Flush(); err != nil {
+		errs = append(errs, fmt.Errorf("flush: %w", err))
+	}
+	return errors.Join(errs...)
 }
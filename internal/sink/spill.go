@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"tg-online-checker/internal/model"
+)
+
+// spillRecord — запись в файле переполнения: тип и данные, чтобы при
+// чтении восстановить исходную структуру.
+type spillRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// spillQueue — очередь на диске для результатов, не поместившихся в буфер
+// ResultSink. Записи дописываются в конец файла и читаются с начала; когда
+// очередь опустела, файл обрезается.
+type spillQueue struct {
+	mu       sync.Mutex
+	file     *os.File
+	readOff  int64
+	writeOff int64
+	count    int
+}
+
+func openSpillQueue(path string) (*spillQueue, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &spillQueue{file: f}, nil
+}
+
+// canSpill сообщает, можно ли сохранить результат на диск и прочитать обратно.
+func canSpill(result any) bool {
+	switch result.(type) {
+	case *model.User, *model.CheckError:
+		return true
+	}
+	return false
+}
+
+func (q *spillQueue) push(result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(spillRecord{Type: recordType(result), Data: data})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.file.WriteAt(line, q.writeOff); err != nil {
+		return err
+	}
+	q.writeOff += int64(len(line))
+	q.count++
+	return nil
+}
+
+// pop читает до n записей из начала очереди.
+func (q *spillQueue) pop(n int) ([]any, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.count == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(io.NewSectionReader(q.file, q.readOff, q.writeOff-q.readOff))
+	var results []any
+	for len(results) < n && dec.More() {
+		var rec spillRecord
+		err := dec.Decode(&rec)
+		var result any
+		if err == nil {
+			result, err = decodeSpillRecord(rec)
+		}
+		if err != nil {
+			// дальше файл не прочитать — остаток очереди потерян
+			lost := q.count - len(results)
+			q.reset()
+			return results, fmt.Errorf("%d spilled results lost: %w", lost, err)
+		}
+		results = append(results, result)
+	}
+	q.readOff += dec.InputOffset()
+	q.count -= len(results)
+
+	if q.count == 0 {
+		if err := q.reset(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// reset очищает очередь. Вызывается под q.mu.
+func (q *spillQueue) reset() error {
+	q.readOff, q.writeOff, q.count = 0, 0, 0
+	return q.file.Truncate(0)
+}
+
+func decodeSpillRecord(rec spillRecord) (any, error) {
+	var result any
+	switch rec.Type {
+	case "user":
+		result = &model.User{}
+	case "error":
+		result = &model.CheckError{}
+	default:
+		return nil, fmt.Errorf("unknown spilled record type %q", rec.Type)
+	}
+	dec := json.NewDecoder(bytes.NewReader(rec.Data))
+	return result, dec.Decode(result)
+}
+
+// close закрывает и удаляет файл очереди.
+func (q *spillQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	name := q.file.Name()
+	if err := q.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
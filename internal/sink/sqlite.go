@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"tg-online-checker/internal/model"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id         INTEGER PRIMARY KEY,
+	username   TEXT NOT NULL,
+	phone      TEXT NOT NULL,
+	first_name TEXT NOT NULL,
+	last_name  TEXT NOT NULL,
+	premium    INTEGER NOT NULL,
+	was_online INTEGER NOT NULL,
+	run_id     TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS observations (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id     TEXT NOT NULL,
+	account_id TEXT NOT NULL,
+	user_id    INTEGER NOT NULL,
+	username   TEXT NOT NULL,
+	premium    INTEGER NOT NULL,
+	was_online INTEGER NOT NULL,
+	checked_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS observations_user_id ON observations (user_id, checked_at);
+CREATE INDEX IF NOT EXISTS observations_run_id ON observations (run_id);
+`
+
+const sqliteUpsertUser = `
+INSERT INTO users (id, username, phone, first_name, last_name, premium, was_online, run_id, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	username   = excluded.username,
+	phone      = excluded.phone,
+	first_name = excluded.first_name,
+	last_name  = excluded.last_name,
+	premium    = excluded.premium,
+	was_online = excluded.was_online,
+	run_id     = excluded.run_id,
+	updated_at = excluded.updated_at`
+
+const sqliteInsertObservation = `
+INSERT INTO observations (run_id, account_id, user_id, username, premium, was_online, checked_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// SQLiteHandler пишет результаты в базу SQLite: таблица users хранит
+// последние данные по каждому Telegram ID, а observations — каждый снимок
+// статуса с ID запуска и аккаунта, которым он получен. Так история
+// накапливается между запусками в одной базе.
+type SQLiteHandler struct {
+	mu    sync.Mutex
+	db    *sql.DB
+	runID string
+}
+
+func NewSQLiteHandler(filename, runID string) (*SQLiteHandler, error) {
+	db, err := sql.Open("sqlite", filename+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &SQLiteHandler{db: db, runID: runID}, nil
+}
+
+func (h *SQLiteHandler) Handle(result any) error {
+	return h.HandleBatch([]any{result})
+}
+
+// HandleBatch записывает пачку результатов одной транзакцией. В базе
+// хранятся только пользователи: записи других типов (например, ошибки
+// проверки) пропускаются.
+func (h *SQLiteHandler) HandleBatch(results []any) error {
+	users := usersOf(results)
+	if len(users) == 0 {
+		return nil
+	}
+	return h.writeUsers(users)
+}
+
+// usersOf выбирает из пачки пользователей.
+func usersOf(results []any) []*model.User {
+	var users []*model.User
+	for _, result := range results {
+		if user, ok := result.(*model.User); ok {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func (h *SQLiteHandler) writeUsers(users []*model.User) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, user := range users {
+		checkedAt := user.CheckedAt
+		if checkedAt == 0 {
+			checkedAt = time.Now().Unix()
+		}
+		if _, err := tx.Exec(sqliteUpsertUser,
+			user.ID, user.Username, user.Phone, user.FirstName, user.LastName,
+			user.Premium, user.WasOnline, h.runID, checkedAt,
+		); err != nil {
+			return fmt.Errorf("sqlite: upsert user %d: %w", user.ID, err)
+		}
+		if _, err := tx.Exec(sqliteInsertObservation,
+			h.runID, user.AccountID, user.ID, user.Username,
+			user.Premium, user.WasOnline, checkedAt,
+		); err != nil {
+			return fmt.Errorf("sqlite: insert observation %d: %w", user.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (h *SQLiteHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.db.Close()
+}
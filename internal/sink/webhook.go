@@ -0,0 +1,349 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"tg-online-checker/internal/model"
+	"time"
+)
+
+// WebhookOptions — параметры WebhookHandler.
+type WebhookOptions struct {
+	URL string
+	// Secret — ключ HMAC-SHA256 подписи тела запроса. Подпись передаётся
+	// в заголовке X-Signature как "sha256=<hex>". Пустой — без подписи.
+	Secret string
+	// BatchSize — сколько записей отправляется одним запросом.
+	BatchSize int
+	// FlushInterval — как часто отправлять неполную пачку.
+	FlushInterval time.Duration
+	// MaxRetries — сколько раз повторять неудачную отправку.
+	MaxRetries int
+	// RetryDelay — пауза перед первым повтором, дальше она удваивается.
+	// По умолчанию 1с.
+	RetryDelay time.Duration
+	// SpoolDir — куда сохраняются пачки, которые не удалось доставить.
+	// Они отправляются повторно при следующей удачной отправке и при
+	// следующем запуске. Пустой — недоставленные пачки теряются.
+	SpoolDir string
+	Header   http.Header
+	Client   *http.Client
+}
+
+func (o *WebhookOptions) setDefaults() {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = time.Second
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+}
+
+// webhookRecord — запись в теле запроса: тип и сами данные.
+type webhookRecord struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type webhookBatch struct {
+	RunID   string          `json:"run_id,omitempty"`
+	SentAt  time.Time       `json:"sent_at"`
+	Results []webhookRecord `json:"results"`
+}
+
+// webhookQueueSize — сколько готовых пачек может ждать отправки.
+const webhookQueueSize = 4
+
+// WebhookHandler отправляет результаты POST запросами с JSON пачками.
+// Неполная пачка уходит не реже раза в FlushInterval, неудачные отправки
+// повторяются с экспоненциальной паузой, а после исчерпания попыток
+// пачка сохраняется в SpoolDir. Отправка идёт в отдельной горутине, поэтому
+// Handle не ждёт сети; если очередь пачек заполнена, пачка сразу уходит в
+// спул, а без спула Handle ждёт места в очереди. Ошибки отправки
+// возвращаются из следующего вызова Handle или из Flush.
+type WebhookHandler struct {
+	mu    sync.Mutex
+	opts  WebhookOptions
+	runID string
+	batch []webhookRecord
+	queue chan webhookBody
+	seq   atomic.Int64
+	errs  fanoutErrors
+	done  chan struct{}
+}
+
+// webhookBody — готовое тело запроса и число записей в нём.
+type webhookBody struct {
+	data  []byte
+	count int
+}
+
+func NewWebhookHandler(opts WebhookOptions, runID string) (*WebhookHandler, error) {
+	opts.setDefaults()
+	if opts.URL == "" {
+		return nil, errors.New("webhook URL is required")
+	}
+	if opts.SpoolDir != "" {
+		if err := os.MkdirAll(opts.SpoolDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create spool dir: %w", err)
+		}
+	}
+
+	h := &WebhookHandler{
+		opts:  opts,
+		runID: runID,
+		queue: make(chan webhookBody, webhookQueueSize),
+		done:  make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+// run отправляет пачки из очереди, раз в FlushInterval — неполную пачку,
+// и досылает спул. Завершается, когда Flush закрывает очередь.
+func (h *WebhookHandler) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.opts.FlushInterval)
+	defer ticker.Stop()
+
+	h.drainSpool()
+	for {
+		select {
+		case body, ok := <-h.queue:
+			if !ok {
+				return
+			}
+			h.send(body)
+		case <-ticker.C:
+			h.mu.Lock()
+			body, err := h.takeBatch()
+			h.mu.Unlock()
+			if err != nil {
+				h.errs.add(err)
+				continue
+			}
+			if body.count > 0 {
+				h.send(body)
+			}
+		}
+	}
+}
+
+func (h *WebhookHandler) Handle(result any) error {
+	record := webhookRecord{Type: recordType(result), Data: result}
+
+	h.mu.Lock()
+	h.batch = append(h.batch, record)
+	if len(h.batch) < h.opts.BatchSize {
+		h.mu.Unlock()
+		return h.errs.take()
+	}
+	body, err := h.takeBatch()
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	h.enqueue(body)
+	return h.errs.take()
+}
+
+// Flush отправляет остаток, дожидается доставки всех пачек и возвращает
+// накопленные ошибки отправки.
+func (h *WebhookHandler) Flush() error {
+	h.mu.Lock()
+	body, err := h.takeBatch()
+	h.mu.Unlock()
+	if err != nil {
+		h.errs.add(err)
+	} else if body.count > 0 {
+		h.queue <- body
+	}
+	close(h.queue)
+	<-h.done
+	return h.errs.take()
+}
+
+// takeBatch забирает накопленную пачку и кодирует её в тело запроса.
+// Вызывается под h.mu.
+func (h *WebhookHandler) takeBatch() (webhookBody, error) {
+	if len(h.batch) == 0 {
+		return webhookBody{}, nil
+	}
+	count := len(h.batch)
+	data, err := json.Marshal(webhookBatch{RunID: h.runID, SentAt: time.Now().UTC(), Results: h.batch})
+	h.batch = nil
+	if err != nil {
+		return webhookBody{}, fmt.Errorf("webhook: %d results lost: %w", count, err)
+	}
+	return webhookBody{data: data, count: count}, nil
+}
+
+// enqueue ставит пачку в очередь отправки. Если очередь заполнена, пачка
+// сохраняется в спул и будет отправлена после ближайшей удачной отправки.
+func (h *WebhookHandler) enqueue(body webhookBody) {
+	if h.opts.SpoolDir != "" {
+		select {
+		case h.queue <- body:
+			return
+		default:
+		}
+		if err := h.spool(body.data); err == nil {
+			return
+		}
+	}
+	h.queue <- body
+}
+
+// recordType возвращает тип записи для поля "type".
+func recordType(result any) string {
+	switch result.(type) {
+	case *model.User:
+		return "user"
+	case *model.CheckError:
+		return "error"
+	}
+	return strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", result), "*"))
+}
+
+// send отправляет пачку; при неудаче сохраняет её в спул. Вызывается из
+// горутины отправки.
+func (h *WebhookHandler) send(body webhookBody) {
+	if err := h.deliver(body.data); err != nil {
+		switch {
+		case h.opts.SpoolDir == "":
+			h.errs.add(fmt.Errorf("webhook: %d results lost: %w", body.count, err))
+		default:
+			if serr := h.spool(body.data); serr != nil {
+				h.errs.add(fmt.Errorf("webhook: %d results lost: %w (spool: %v)", body.count, err, serr))
+			} else {
+				h.errs.add(fmt.Errorf("webhook: %d results spooled: %w", body.count, err))
+			}
+		}
+		return
+	}
+	h.drainSpool()
+}
+
+// deliver отправляет тело запроса, повторяя временные ошибки с паузой
+// RetryDelay, затем вдвое больше и т.д.
+func (h *WebhookHandler) deliver(body []byte) error {
+	delay := h.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(body)
+		if err == nil || !retry || attempt >= h.opts.MaxRetries {
+			return err
+		}
+		log.Printf("⚠️ webhook: %v, retry in %v", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post выполняет один запрос и сообщает, имеет ли смысл его повторить:
+// сетевые ошибки, 429 и 5xx повторяются, остальные ответы — нет.
+func (h *WebhookHandler) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range h.opts.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.opts.Secret != "" {
+		req.Header.Set("X-Signature", "sha256="+Sign(h.opts.Secret, body))
+	}
+
+	resp, err := h.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign возвращает hex HMAC-SHA256 подпись тела — так же её проверяет
+// получатель.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// spool сохраняет недоставленную пачку в SpoolDir.
+func (h *WebhookHandler) spool(body []byte) error {
+	name := fmt.Sprintf("%s-%06d.json", time.Now().UTC().Format("20060102T150405.000000000"), h.seq.Add(1))
+	tmp := filepath.Join(h.opts.SpoolDir, name+".tmp")
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(h.opts.SpoolDir, name))
+}
+
+// drainSpool досылает сохранённые пачки, начиная со старых, и
+// останавливается на первой неудаче. Вызывается из горутины отправки.
+func (h *WebhookHandler) drainSpool() {
+	if h.opts.SpoolDir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(h.opts.SpoolDir, "*.json"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("⚠️ webhook: cant read spooled batch %s: %v", path, err)
+			continue
+		}
+		if retry, err := h.post(body); err != nil {
+			if retry {
+				return
+			}
+			// получатель не примет пачку и повторно — откладываем её в сторону
+			log.Printf("⚠️ webhook: spooled batch %s rejected: %v", filepath.Base(path), err)
+			if err := os.Rename(path, path+".rejected"); err != nil {
+				log.Printf("⚠️ webhook: cant move rejected batch %s: %v", path, err)
+				return
+			}
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("⚠️ webhook: cant remove spooled batch %s: %v", path, err)
+			return
+		}
+		log.Printf("📤 webhook: delivered spooled batch %s", filepath.Base(path))
+	}
+}
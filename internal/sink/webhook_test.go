@@ -0,0 +1,175 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"tg-online-checker/internal/model"
+	"time"
+)
+
+// webhookServer — тестовый получатель: отвечает статусами из statuses по
+// очереди (дальше — 200) и запоминает принятые тела.
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	attempts atomic.Int64
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.attempts.Add(1)
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		if status == http.StatusOK {
+			s.bodies = append(s.bodies, body)
+			s.headers = append(s.headers, r.Header.Clone())
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testWebhookOptions(url string) WebhookOptions {
+	return WebhookOptions{
+		URL:           url,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryDelay:    time.Millisecond,
+	}
+}
+
+func TestWebhookSignsBatches(t *testing.T) {
+	server := newWebhookServer(t)
+	opts := testWebhookOptions(server.URL)
+	opts.Secret = "secret"
+	h, err := NewWebhookHandler(opts, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Handle(&model.User{ID: 1, Username: "user"})
+	h.Handle(&model.CheckError{Username: "missing", Error: "not found"})
+	h.Handle(&model.User{ID: 2})
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(server.bodies))
+	}
+	for i, body := range server.bodies {
+		if got, want := server.headers[i].Get("X-Signature"), "sha256="+Sign("secret", body); got != want {
+			t.Errorf("request %d: X-Signature = %q, want %q", i, got, want)
+		}
+	}
+
+	var batch struct {
+		RunID   string `json:"run_id"`
+		Results []struct {
+			Type string `json:"type"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(server.bodies[0], &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.RunID != "run-1" || len(batch.Results) != 2 ||
+		batch.Results[0].Type != "user" || batch.Results[1].Type != "error" {
+		t.Errorf("unexpected first batch: %s", server.bodies[0])
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	opts := testWebhookOptions(server.URL)
+	opts.MaxRetries = 2
+	h, err := NewWebhookHandler(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Handle(&model.User{ID: 1})
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.attempts.Load(); got != 3 {
+		t.Errorf("got %d attempts, want 3", got)
+	}
+	if len(server.bodies) != 1 {
+		t.Errorf("got %d delivered batches, want 1", len(server.bodies))
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	server := newWebhookServer(t, http.StatusBadRequest)
+	opts := testWebhookOptions(server.URL)
+	opts.MaxRetries = 3
+	h, err := NewWebhookHandler(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Handle(&model.User{ID: 1})
+	if err := h.Flush(); err == nil {
+		t.Error("Flush succeeded after a rejected batch")
+	}
+	if got := server.attempts.Load(); got != 1 {
+		t.Errorf("got %d attempts, want 1", got)
+	}
+}
+
+func TestWebhookSpool(t *testing.T) {
+	spool := t.TempDir()
+
+	down := newWebhookServer(t, http.StatusBadGateway, http.StatusBadGateway)
+	opts := testWebhookOptions(down.URL)
+	opts.MaxRetries = 1
+	opts.SpoolDir = spool
+	h, err := NewWebhookHandler(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Handle(&model.User{ID: 1})
+	if err := h.Flush(); err == nil {
+		t.Error("Flush succeeded while the receiver was down")
+	}
+	spooled, _ := filepath.Glob(filepath.Join(spool, "*.json"))
+	if len(spooled) != 1 {
+		t.Fatalf("got %d spooled batches, want 1", len(spooled))
+	}
+	want, _ := os.ReadFile(spooled[0])
+
+	// следующий запуск досылает спул при старте
+	up := newWebhookServer(t)
+	opts.URL = up.URL
+	h, err = NewWebhookHandler(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(up.bodies) != 1 || string(up.bodies[0]) != string(want) {
+		t.Errorf("spooled batch was not delivered as is: %q", up.bodies)
+	}
+	if left, _ := os.ReadDir(spool); len(left) != 0 {
+		t.Errorf("spool is not empty after delivery: %d files", len(left))
+	}
+}
@@ -0,0 +1,268 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"tg-online-checker/internal/model"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxResultsSheet = "Results"
+	xlsxSummarySheet = "Summary"
+	// xlsxDateFormat — формат дат в ячейках; значения хранятся в UTC.
+	xlsxDateFormat = "yyyy-mm-dd hh:mm:ss"
+	// xlsxTextFormat — встроенный формат "@": Excel не превращает телефоны
+	// и username в числа даже при редактировании ячейки.
+	xlsxTextFormat = 49
+)
+
+// SummaryItem — строка листа итогов: название и значение.
+type SummaryItem struct {
+	Name  string
+	Value any
+}
+
+// XLSXOptions — параметры XLSXHandler.
+type XLSXOptions struct {
+	// Columns — колонки листа результатов; по умолчанию DefaultColumns.
+	Columns []Column
+	// Summary возвращает дополнительные строки листа итогов (например,
+	// счётчики аккаунтов); вызывается в Flush.
+	Summary func() []SummaryItem
+}
+
+// XLSXHandler пишет результаты в книгу Excel: лист Results с типизированными
+// колонками, закреплённым заголовком и автофильтром и лист Summary с
+// итогами запуска. Строки пишутся потоково, но сам файл появляется на
+// диске только в Flush.
+type XLSXHandler struct {
+	mu       sync.Mutex
+	filename string
+	file     *excelize.File
+	stream   *excelize.StreamWriter
+	columns  []Column
+	summary  func() []SummaryItem
+	started  time.Time
+	rows     int
+	users    int
+	errors   int
+
+	headerStyle int
+	dateStyle   int
+	textStyle   int
+}
+
+func NewXLSXHandler(filename string, opts XLSXOptions) (*XLSXHandler, error) {
+	if len(opts.Columns) == 0 {
+		opts.Columns = DefaultColumns
+	}
+	h := &XLSXHandler{
+		filename: filename,
+		file:     excelize.NewFile(),
+		columns:  opts.Columns,
+		summary:  opts.Summary,
+		started:  time.Now(),
+	}
+	if err := h.init(); err != nil {
+		h.file.Close()
+		return nil, fmt.Errorf("failed to prepare XLSX sheet: %w", err)
+	}
+	return h, nil
+}
+
+// init создаёт стили и лист результатов с заголовком.
+func (h *XLSXHandler) init() error {
+	var err error
+	dateFormat := xlsxDateFormat
+	if h.headerStyle, err = h.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return err
+	}
+	if h.dateStyle, err = h.file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return err
+	}
+	if h.textStyle, err = h.file.NewStyle(&excelize.Style{NumFmt: xlsxTextFormat}); err != nil {
+		return err
+	}
+
+	if err := h.file.SetSheetName(h.file.GetSheetName(0), xlsxResultsSheet); err != nil {
+		return err
+	}
+	if h.stream, err = h.file.NewStreamWriter(xlsxResultsSheet); err != nil {
+		return err
+	}
+	// панели и ширины задаются до первой строки
+	if err := h.stream.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	for i, col := range h.columns {
+		width := 16.0
+		if col.Kind == "time" {
+			width = 20
+		}
+		if err := h.stream.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
+
+	header := make([]any, len(h.columns))
+	for i, name := range Headers(h.columns) {
+		header[i] = excelize.Cell{StyleID: h.headerStyle, Value: name}
+	}
+	h.rows = 1
+	return h.stream.SetRow("A1", header)
+}
+
+func (h *XLSXHandler) Handle(result any) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	val := reflect.ValueOf(result)
+	row := make([]any, len(h.columns))
+	for i, col := range h.columns {
+		row[i] = h.cell(col, val)
+	}
+	cell, err := excelize.CoordinatesToCellName(1, h.rows+1)
+	if err != nil {
+		return err
+	}
+	if err := h.stream.SetRow(cell, row); err != nil {
+		return err
+	}
+	h.rows++
+	switch result.(type) {
+	case *model.User:
+		h.users++
+	case *model.CheckError:
+		h.errors++
+	}
+	return nil
+}
+
+// cell возвращает значение ячейки с типом по колонке: даты — датами,
+// строки (телефоны, username) — текстом, числа и флаги — как есть.
+func (h *XLSXHandler) cell(col Column, result reflect.Value) any {
+	v, ok := fieldByPath(result, col.Field)
+	if !ok {
+		return nil
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch col.Kind {
+	case "time":
+		if t, ok := cellTime(v); ok {
+			return excelize.Cell{StyleID: h.dateStyle, Value: t}
+		}
+		return nil
+	case "bool":
+		if v.Kind() == reflect.Bool {
+			return v.Bool()
+		}
+	case "text":
+		return excelize.Cell{StyleID: h.textStyle, Value: formatText(v)}
+	}
+	if col.Format != nil && col.Kind == "" {
+		// форматтер задан в коде — его результат пишется как текст
+		return excelize.Cell{StyleID: h.textStyle, Value: col.Format(v)}
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return excelize.Cell{StyleID: h.dateStyle, Value: t.UTC()}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		return excelize.Cell{StyleID: h.textStyle, Value: v.String()}
+	}
+	return excelize.Cell{StyleID: h.textStyle, Value: formatText(v)}
+}
+
+// writeSummary заполняет лист итогов: счётчики записей и строки из
+// XLSXOptions.Summary.
+func (h *XLSXHandler) writeSummary() error {
+	if _, err := h.file.NewSheet(xlsxSummarySheet); err != nil {
+		return err
+	}
+	items := []SummaryItem{
+		{Name: "Начало", Value: h.started.UTC()},
+		{Name: "Окончание", Value: time.Now().UTC()},
+		{Name: "Пользователей", Value: h.users},
+		{Name: "Ошибок", Value: h.errors},
+	}
+	if h.summary != nil {
+		items = append(items, h.summary()...)
+	}
+
+	for i, item := range items {
+		row := i + 1
+		if err := h.file.SetCellValue(xlsxSummarySheet, fmt.Sprintf("A%d", row), item.Name); err != nil {
+			return err
+		}
+		value := fmt.Sprintf("B%d", row)
+		if err := h.file.SetCellValue(xlsxSummarySheet, value, item.Value); err != nil {
+			return err
+		}
+		if _, ok := item.Value.(time.Time); ok {
+			if err := h.file.SetCellStyle(xlsxSummarySheet, value, value, h.dateStyle); err != nil {
+				return err
+			}
+		}
+	}
+	if err := h.file.SetCellStyle(xlsxSummarySheet, "A1", fmt.Sprintf("A%d", len(items)), h.headerStyle); err != nil {
+		return err
+	}
+	return h.file.SetColWidth(xlsxSummarySheet, "A", "B", 20)
+}
+
+// Flush дописывает лист результатов, добавляет итоги и сохраняет книгу.
+func (h *XLSXHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// автофильтр ставится до Flush потока: после него лист уже записан
+	last, err := excelize.CoordinatesToCellName(len(h.columns), h.rows)
+	if err != nil {
+		return errors.Join(err, h.file.Close())
+	}
+	if err := h.file.AutoFilter(xlsxResultsSheet, "A1:"+last, nil); err != nil {
+		return errors.Join(err, h.file.Close())
+	}
+	if err := h.stream.Flush(); err != nil {
+		return errors.Join(err, h.file.Close())
+	}
+	if err := h.writeSummary(); err != nil {
+		return errors.Join(fmt.Errorf("failed to write XLSX summary: %w", err), h.file.Close())
+	}
+	h.file.SetActiveSheet(0)
+	if err := h.file.SaveAs(h.filename); err != nil {
+		return errors.Join(err, h.file.Close())
+	}
+	return h.file.Close()
+}
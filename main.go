@@ -2,22 +2,32 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 	"sync"
 	"tg-online-checker/internal/account"
 	"tg-online-checker/internal/model"
 	"tg-online-checker/internal/proxy"
 	"tg-online-checker/internal/sink"
+	"time"
 )
 
 func main() {
 	cfg := MustLoadConfig()
 
+	if len(os.Args) > 1 {
+		if err := RunCommand(cfg, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Создаем контекст с возможностью отмены
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	proxies, err := proxy.Get(cfg.File.Proxy)
+	pool, err := newProxyPool(ctx, cfg)
 	if err != nil {
 		log.Fatalf("cant get proxies: %v", err)
 	}
@@ -25,12 +35,62 @@ func main() {
 	// WaitGroup для ожидания завершения всех MonitorWorker
 	var wg sync.WaitGroup
 
-	handler, err := sink.NewCSVHandler(cfg.File.Result) // или CSV/Console
+	// ID запуска отличает результаты этого запуска в накопительных хранилищах
+	runID := time.Now().Format("20060102-150405")
+	log.Printf("[main] run %s", runID)
+
+	// Менеджер аккаунтов создаётся позже; его счётчики нужны только для
+	// итогов при закрытии файла результатов
+	var manager *account.AccountManager
+	summary := func() []sink.SummaryItem {
+		items := []sink.SummaryItem{{Name: "Запуск", Value: runID}}
+		if manager == nil {
+			return items
+		}
+		totals := manager.Totals()
+		return append(items,
+			sink.SummaryItem{Name: "Аккаунтов", Value: totals.Total},
+			sink.SummaryItem{Name: "Рабочих", Value: totals.Valid},
+			sink.SummaryItem{Name: "В бане", Value: totals.Banned},
+			sink.SummaryItem{Name: "С таймаутом", Value: totals.Flood},
+		)
+	}
+
+	handler, err := newResultHandler(cfg, runID, summary)
 	if err != nil {
-		log.Fatalf("Ошибка создания csv обработчика: %v", err)
+		log.Fatalf("Ошибка создания обработчика результатов: %v", err)
 	}
-	resultSink := sink.NewResultSink(handler)
-	defer resultSink.Close()
+	bufferPolicy, err := sink.ParseFullPolicy(cfg.Result.BufferPolicy)
+	if err != nil {
+		log.Fatalf("invalid RESULT_BUFFER_POLICY: %v", err)
+	}
+	resultSink, err := sink.NewResultSink(handler, sink.Options{
+		OnError: func(err error) {
+			log.Printf("[main] result write error: %v", err)
+		},
+		MaxErrors:  cfg.Result.MaxErrors,
+		BufferSize: cfg.Result.BufferSize,
+		BatchSize:  cfg.Result.BatchSize,
+		OnFull:     bufferPolicy,
+		SpillPath:  cfg.Result.SpillFile,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка создания обработчика результатов: %v", err)
+	}
+	defer func() {
+		if err := resultSink.Close(); err != nil {
+			log.Printf("[main] results are incomplete: %v", err)
+		}
+	}()
+	// Слишком много ошибок записи — результаты всё равно теряются, останавливаем запуск
+	go func() {
+		select {
+		case <-resultSink.Aborted():
+			log.Printf("[main] %d result write errors, aborting run", resultSink.Failures())
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
 	// Читаем пользователей
 	users, err := GetUsers(cfg.File.Users)
@@ -38,11 +98,38 @@ func main() {
 		log.Fatalf("cant get users: %v", err)
 	}
 
+	geoPolicy, err := account.ParseGeoPolicy(cfg.Proxy.GeoPolicy)
+	if err != nil {
+		log.Fatalf("invalid PROXY_GEO_POLICY: %v", err)
+	}
+
 	taskChan := make(chan model.Command, len(users))
-	manager, err := account.NewManager(cfg.Dir.Sessions, proxies)
+	manager, err = account.NewManager(cfg.Dir.Sessions, cfg.Dir.Quarantine, pool, geoPolicy)
 	if err != nil {
 		log.Fatalf("cant create account manager: %v", err)
 	}
+
+	// События аккаунтов: лог, счётчики и, если задан, аудит-файл
+	eventCounter := NewEventCounter()
+	defer eventCounter.Print()
+	manager.Events().Subscribe(logEvent)
+	manager.Events().Subscribe(eventCounter.Handle)
+	if cfg.File.Audit != "" {
+		audit, err := NewAuditLog(cfg.File.Audit)
+		if err != nil {
+			log.Fatalf("cant open audit file: %v", err)
+		}
+		defer audit.Close()
+		manager.Events().Subscribe(audit.Handle)
+	}
+
+	// Подхватываем новые и удалённые сессии без перезапуска
+	go func() {
+		if err := manager.Watch(ctx); err != nil {
+			log.Printf("[main] session watcher stopped: %v", err)
+		}
+	}()
+
 	doneProducing := make(chan struct{})
 
 	worker := Worker{
@@ -73,3 +160,97 @@ func main() {
 	wg.Wait()
 	log.Println("[main] all workers completed, shutting down")
 }
+
+// newProxyPool проверяет прокси из всех источников и запускает пул, который
+// перепроверяет их в фоне, пополняется из источников и переводит аккаунты
+// с упавших прокси на запасные. В режиме direct возвращает nil.
+// PROXY_RECHECK_INTERVAL <= 0 отключает фоновую перепроверку,
+// PROXY_REFRESH_INTERVAL <= 0 — пополнение из источников.
+func newProxyPool(ctx context.Context, cfg *Config) (*proxy.Pool, error) {
+	switch cfg.Proxy.Mode {
+	case "direct":
+		log.Println("[main] proxy mode: direct connections")
+		return nil, nil
+	case "pool", "":
+	default:
+		return nil, fmt.Errorf("unknown PROXY_MODE %q", cfg.Proxy.Mode)
+	}
+
+	// Проверяем прокси на адресах DC из сессий и стандартных DC Telegram
+	checkOpts, err := proxyCheckOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sources := proxySources(cfg)
+	pool := proxy.NewPool(nil, checkOpts, cfg.Proxy.MaxFailures)
+	for _, src := range sources {
+		list, err := proxy.Load(ctx, src, checkOpts)
+		if err != nil {
+			return nil, err
+		}
+		pool.AddSource(src, list)
+	}
+
+	if cfg.Proxy.RecheckInterval > 0 {
+		go pool.Run(ctx, cfg.Proxy.RecheckInterval)
+	} else {
+		log.Println("[main] proxy recheck disabled")
+	}
+	if cfg.Proxy.RefreshInterval > 0 {
+		for _, src := range sources {
+			go pool.RunSource(ctx, src, cfg.Proxy.RefreshInterval)
+		}
+	} else {
+		log.Println("[main] proxy source refresh disabled")
+	}
+	return pool, nil
+}
+
+// proxyCheckOptions собирает параметры проверки прокси из конфига.
+func proxyCheckOptions(cfg *Config) (proxy.CheckOptions, error) {
+	opts := proxy.CheckOptions{
+		Targets:     cfg.Proxy.CheckTargets,
+		Concurrency: cfg.Proxy.CheckConcurrency,
+		Timeout:     cfg.Proxy.CheckTimeout,
+		EgressURL:   cfg.Proxy.EgressURL,
+	}
+	if len(opts.Targets) == 0 {
+		opts.Targets = proxy.DCTargets(account.SessionAddrs(cfg.Dir.Sessions, cfg.Dir.Quarantine)...)
+	}
+	if cfg.Proxy.CacheFile != "" {
+		cache, err := proxy.OpenCache(cfg.Proxy.CacheFile, cfg.Proxy.CacheTTL)
+		if err != nil {
+			return opts, fmt.Errorf("cant open proxy check cache: %w", err)
+		}
+		opts.Cache = cache
+	}
+	// Страна прокси определяется по внешнему IP, поэтому GeoIP база
+	// имеет смысл только вместе с IP-echo сервисом
+	if cfg.Proxy.GeoIPDB != "" {
+		if opts.EgressURL == "" {
+			log.Println("[main] PROXY_GEOIP_DB is set without PROXY_EGRESS_URL, proxy countries are unknown")
+		}
+		geo, err := proxy.OpenGeoDB(cfg.Proxy.GeoIPDB)
+		if err != nil {
+			return opts, fmt.Errorf("cant open GeoIP database: %w", err)
+		}
+		opts.GeoDB = geo
+	}
+	return opts, nil
+}
+
+// proxySources собирает источники прокси из конфига: файл и/или API провайдера.
+func proxySources(cfg *Config) []proxy.Source {
+	var sources []proxy.Source
+	if cfg.File.Proxy != "" {
+		sources = append(sources, &proxy.FileSource{Path: cfg.File.Proxy})
+	}
+	if cfg.Proxy.SourceURL != "" {
+		sources = append(sources, &proxy.HTTPSource{
+			URL:       cfg.Proxy.SourceURL,
+			Format:    cfg.Proxy.SourceFormat,
+			RotateURL: cfg.Proxy.RotateURL,
+		})
+	}
+	return sources
+}
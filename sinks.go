@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"tg-online-checker/internal/sink"
+)
+
+// sinkSpec описывает один обработчик результатов: формат, файл (или DSN)
+// и выражение фильтра.
+type sinkSpec struct {
+	Format string
+	Target string
+	Filter string
+}
+
+// parseSinkSpec разбирает элемент RESULT_SINKS вида "format:target|filter",
+// например "csv:results.csv", "sqlite:history.db|premium&online<24h" или
+// "jsonl:errors.jsonl|errors". Для postgres target — DSN, для webhook —
+// URL; если они не указаны, берутся RESULT_POSTGRES_DSN и RESULT_WEBHOOK_URL.
+func parseSinkSpec(value string) (sinkSpec, error) {
+	value, filter, _ := strings.Cut(value, "|")
+	format, target, _ := strings.Cut(strings.TrimSpace(value), ":")
+	spec := sinkSpec{
+		Format: strings.ToLower(strings.TrimSpace(format)),
+		Target: strings.TrimSpace(target),
+		Filter: strings.TrimSpace(filter),
+	}
+	if spec.Format == "" {
+		return spec, fmt.Errorf("invalid sink %q: format is required", value)
+	}
+	return spec, nil
+}
+
+// newResultHandler создаёт обработчик результатов. Если задан RESULT_SINKS,
+// результаты рассылаются всем перечисленным обработчикам, иначе — одному
+// обработчику из RESULT_FORMAT и RESULT_FILE. summary дополняет лист
+// итогов XLSX.
+func newResultHandler(cfg *Config, runID string, summary func() []sink.SummaryItem) (sink.ResultHandler, error) {
+	specs := []sinkSpec{{Format: cfg.Result.Format, Target: cfg.File.Result, Filter: cfg.Result.Filter}}
+	if len(cfg.Result.Sinks) > 0 {
+		specs = specs[:0]
+		for _, value := range cfg.Result.Sinks {
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			spec, err := parseSinkSpec(value)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	var routes []sink.Route
+	for i, spec := range specs {
+		filter, err := sink.ParseFilter(spec.Filter)
+		if err != nil {
+			closeRoutes(routes)
+			return nil, err
+		}
+		handler, err := openResultHandler(cfg, spec, runID, summary)
+		if err != nil {
+			closeRoutes(routes)
+			return nil, fmt.Errorf("%s: %w", spec.Format, err)
+		}
+		routes = append(routes, sink.Route{
+			Name:         routeName(i, spec),
+			Handler:      handler,
+			Filter:       filter,
+			QueueTimeout: cfg.Result.SinkTimeout,
+		})
+	}
+	return sink.NewFanoutHandler(routes...), nil
+}
+
+// routeName возвращает имя обработчика для логов. Для postgres и webhook
+// target — DSN или URL, в которых могут быть пароль или токен, поэтому
+// вместо него используется номер обработчика.
+func routeName(i int, spec sinkSpec) string {
+	switch spec.Format {
+	case "postgres", "webhook":
+		return fmt.Sprintf("%s#%d", spec.Format, i+1)
+	}
+	return spec.Format + ":" + spec.Target
+}
+
+// openResultHandler создаёт обработчик по формату из spec.
+func openResultHandler(cfg *Config, spec sinkSpec, runID string, summary func() []sink.SummaryItem) (sink.ResultHandler, error) {
+	switch spec.Format {
+	case "csv", "":
+		opts, err := csvOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return openFileHandler(cfg, spec.Format, spec.Target, func(filename string) (sink.ResultHandler, error) {
+			return sink.NewCSVHandler(filename, opts)
+		})
+	case "json":
+		opts := sink.JSONOptions{
+			Append: cfg.Result.Append,
+			Dedupe: cfg.Result.Dedupe,
+		}
+		return openFileHandler(cfg, spec.Format, spec.Target, func(filename string) (sink.ResultHandler, error) {
+			return sink.NewJSONHandler(filename, opts)
+		})
+	case "jsonl":
+		compression, err := sink.ParseCompression(cfg.Result.Compression)
+		if err != nil {
+			return nil, err
+		}
+		return openFileHandler(cfg, spec.Format, spec.Target, func(filename string) (sink.ResultHandler, error) {
+			return sink.NewJSONLHandler(filename, compression)
+		})
+	case "xlsx":
+		opts := sink.XLSXOptions{Summary: summary}
+		if len(cfg.Result.CSVColumns) > 0 {
+			columns, err := sink.ParseColumns(cfg.Result.CSVColumns)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RESULT_CSV_COLUMNS: %w", err)
+			}
+			opts.Columns = columns
+		}
+		return openFileHandler(cfg, spec.Format, spec.Target, func(filename string) (sink.ResultHandler, error) {
+			return sink.NewXLSXHandler(filename, opts)
+		})
+	case "parquet":
+		opts := sink.ParquetOptions{
+			Codec:        cfg.Result.ParquetCodec,
+			RowGroupSize: cfg.Result.ParquetRowGroup,
+		}
+		return openFileHandler(cfg, spec.Format, spec.Target, func(filename string) (sink.ResultHandler, error) {
+			return sink.NewParquetHandler(filename, opts)
+		})
+	case "sqlite":
+		return sink.NewSQLiteHandler(spec.Target, runID)
+	case "postgres":
+		dsn := spec.Target
+		if dsn == "" {
+			dsn = cfg.Result.PostgresDSN
+		}
+		return sink.NewPostgresHandler(context.Background(), sink.PostgresOptions{
+			DSN:               dsn,
+			UsersTable:        cfg.Result.UsersTable,
+			ObservationsTable: cfg.Result.ObsTable,
+			RunID:             runID,
+			BatchSize:         cfg.Result.BatchSize,
+			MaxRetries:        cfg.Result.MaxRetries,
+		})
+	case "webhook":
+		url := spec.Target
+		if url == "" {
+			url = cfg.Result.WebhookURL
+		}
+		return sink.NewWebhookHandler(sink.WebhookOptions{
+			URL:           url,
+			Secret:        cfg.Result.Secret,
+			BatchSize:     cfg.Result.BatchSize,
+			FlushInterval: cfg.Result.Interval,
+			MaxRetries:    cfg.Result.MaxRetries,
+			SpoolDir:      cfg.Result.SpoolDir,
+		}, runID)
+	}
+	return nil, fmt.Errorf("unknown result format %q", spec.Format)
+}
+
+// openFileHandler открывает файловый обработчик; если задано хоть одно
+// условие RESULT_ROTATE_*, результаты пишутся в ротируемые файлы с меткой
+// времени в имени. Дедупликация при ротации действует в пределах файла.
+func openFileHandler(cfg *Config, format, filename string, open func(filename string) (sink.ResultHandler, error)) (sink.ResultHandler, error) {
+	compression, err := sink.ParseCompression(cfg.Result.RotateCompression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESULT_ROTATE_COMPRESSION: %w", err)
+	}
+	opts := sink.RotateOptions{
+		MaxRecords:  cfg.Result.RotateRecords,
+		MaxBytes:    cfg.Result.RotateSize,
+		Interval:    cfg.Result.RotateInterval,
+		Compression: compression,
+	}
+	if !opts.Enabled() {
+		return open(filename)
+	}
+	// каждый файл ротации новый, дописывать некуда
+	if cfg.Result.Append {
+		return nil, fmt.Errorf("RESULT_APPEND cannot be combined with RESULT_ROTATE_*")
+	}
+	// json и xlsx пишут файл только при закрытии, его размер до этого неизвестен
+	if opts.MaxBytes > 0 && (format == "json" || format == "xlsx") {
+		return nil, fmt.Errorf("RESULT_ROTATE_SIZE is not supported for %s, use RESULT_ROTATE_RECORDS or RESULT_ROTATE_INTERVAL", format)
+	}
+	return sink.NewRotatingHandler(filename, opts, open)
+}
+
+// csvOptions собирает схему CSV из конфига.
+func csvOptions(cfg *Config) (sink.CSVOptions, error) {
+	opts := sink.CSVOptions{
+		BOM:    cfg.Result.CSVBOM,
+		Append: cfg.Result.Append,
+		Dedupe: cfg.Result.Dedupe,
+	}
+	if len(cfg.Result.CSVColumns) > 0 {
+		columns, err := sink.ParseColumns(cfg.Result.CSVColumns)
+		if err != nil {
+			return opts, fmt.Errorf("invalid RESULT_CSV_COLUMNS: %w", err)
+		}
+		opts.Columns = columns
+	}
+	switch delimiter := cfg.Result.CSVDelimiter; delimiter {
+	case "", ",":
+	case "tab", `\t`:
+		opts.Delimiter = '\t'
+	default:
+		r := []rune(delimiter)
+		if len(r) != 1 {
+			return opts, fmt.Errorf("invalid RESULT_CSV_DELIMITER %q", delimiter)
+		}
+		opts.Delimiter = r[0]
+	}
+	return opts, nil
+}
+
+// closeRoutes финализирует уже созданные обработчики, если следующий
+// создать не удалось.
+func closeRoutes(routes []sink.Route) {
+	for _, r := range routes {
+		_ = r.Handler.Flush()
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -30,7 +31,7 @@ type Result struct {
 	Username string
 }
 
-func (w *Worker) handleTask(api *tg.Client, task model.Command) error {
+func (w *Worker) handleTask(api *tg.Client, acc *account.Account, task model.Command) error {
 
 	peer, err := api.ContactsResolveUsername(w.ctx, &tg.ContactsResolveUsernameRequest{Username: task.Username})
 	if err != nil {
@@ -43,6 +44,8 @@ func (w *Worker) handleTask(api *tg.Client, task model.Command) error {
 		}
 
 		user := model.NewUser(tgUser)
+		user.AccountID = acc.ID
+		user.CheckedAt = time.Now().Unix()
 		w.sink.Submit(user)
 	}
 
@@ -56,16 +59,23 @@ func (w *Worker) start(acc *account.Account) {
 
 	client := telegram.NewClient(acc.AppID, acc.AppHash, telegram.Options{
 		SessionStorage: acc.Storage,
-		Resolver:       acc.Resolver,
+		Resolver:       acc.GetResolver(),
 	})
 
 	err := client.Run(w.ctx, func(ctx context.Context) error {
 
 		log.Printf("[%s] Starting worker", acc.ID)
+		w.manager.ReportProxySuccess(acc)
 
 		api := client.API()
 
 		for {
+			// Аккаунт могли вывести из пула или поймать на нём ограничение —
+			// отпускаем его, не забирая новую задачу из очереди.
+			if !acc.IsValid() {
+				return nil
+			}
+
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -79,21 +89,23 @@ func (w *Worker) start(acc *account.Account) {
 					continue // защита от мусора, если вдруг попадет
 				}
 
-				if !acc.IsValid() {
-
-					return nil
-				}
-
-				if err := w.handleTask(api, task); err != nil {
+				if err := w.handleTask(api, acc, task); err != nil {
 
 					floodWait := isFloodWait(err)
 					fmt.Println("FW ", floodWait)
 					if floodWait != 0 {
 						acc.SetFloodWait(floodWait)
 					}
-					if isBanned(err) {
-						acc.MarkBanned()
+					if reason := banReason(err); reason != "" {
+						acc.MarkBanned(reason)
 					}
+					acc.ReportError(err)
+					w.sink.Submit(&model.CheckError{
+						Username:  task.Username,
+						AccountID: acc.ID,
+						Error:     err.Error(),
+						CheckedAt: time.Now().Unix(),
+					})
 					log.Printf("[%s] error handling task: %v", acc.ID, err)
 				}
 			}
@@ -101,6 +113,18 @@ func (w *Worker) start(acc *account.Account) {
 	})
 
 	if err != nil {
+		reason := banReason(err)
+		if reason != "" {
+			acc.MarkBanned(reason)
+		}
+		if !errors.Is(err, context.Canceled) {
+			acc.ReportError(err)
+			if reason == "" {
+				// Клиент не смог подключиться или отвалился — возможно, проблема
+				// в прокси; ошибки, не связанные с транспортом, менеджер отбросит
+				w.manager.ReportProxyFailure(acc, err)
+			}
+		}
 		log.Printf("[%s] client exited: %v", acc.ID, err)
 	}
 }
@@ -162,14 +186,27 @@ func isChannelClosedAndEmpty(ch <-chan model.Command) bool {
 	}
 }
 
-func isBanned(err error) bool {
+// banReasons — ошибки Telegram, после которых аккаунт больше не пригоден.
+var banReasons = []string{
+	"PHONE_NUMBER_BANNED",
+	"USER_DEACTIVATED_BAN",
+	"USER_DEACTIVATED",
+	"AUTH_KEY_UNREGISTERED",
+	"SESSION_REVOKED",
+}
+
+// banReason возвращает код ошибки бана/деактивации или пустую строку.
+func banReason(err error) string {
 	if err == nil {
-		return false
+		return ""
 	}
 	msg := err.Error()
-	return strings.Contains(msg, "PHONE_NUMBER_BANNED") ||
-		strings.Contains(msg, "USER_DEACTIVATED") ||
-		strings.Contains(msg, "AUTH_KEY_UNREGISTERED")
+	for _, reason := range banReasons {
+		if strings.Contains(msg, reason) {
+			return reason
+		}
+	}
+	return ""
 }
 
 var floodWaitRegex = regexp.MustCompile(`FLOOD_WAIT \((\d+)\)`)